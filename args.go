@@ -13,41 +13,148 @@ import (
 var (
 	verboseLog                bool
 	quietLog                  bool
+	headless                  bool
 	connectAddress            string
 	username                  string
 	password                  string
 	civAddress                byte
 	controllerAddress         byte
 	serialTCPPort             uint16
+	serialTCPKeepAlive        time.Duration
 	enableSerialDevice        bool
 	rigctldPort               uint16
+	civCmdPort                uint16
 	runCmd                    string
 	runCmdOnSerialPortCreated string
 	statusLogInterval         time.Duration
 	setDataModeOnTx           bool
 	debugPackets              bool
+	swrAsReturnLoss           bool
+	spotsFile                 string
+	showBothVFOs              bool
+	cwRTTYFilterIdx           int
+	ttsCmd                    string
+	bandEdgeMargin            uint64
+	maxTunePwrLevel           int
+	tuneTimeout               time.Duration
+	showBand                  bool
+	maxTuningAccel            uint
+	powerOffOnExit            bool
+	radioPowerSaveEnabled     bool
+	homeFreq                  uint64
+	homeModeIdx               int
+	keepModeOnUnknown         bool
+	civCmdGap                 time.Duration
+	idlePollAfter             time.Duration
+	maxSessionDuration        time.Duration
+	idlePollInterval          time.Duration
+	stateCSVFile              string
+	execFailPolicy            string
+	serialDevicePath          string
+	timeStations              []timeStation
+	watchedFreqs              []watchedFreq
+	watchedFreqTolerance      uint64
+	monitorSquelch            bool
+	txAudioHighPassFreq       uint
+	txAudioPreEmphasis        bool
+	txAudioCompressRatio      uint
+	rxAudioAGCEnabled         bool
+	statusJSONFile            string
+	statusJSONInterval        time.Duration
+	statusJSONDelta           bool
+	extPTTPath                string
+	sessionLogFile            string
+	autoSaveInterval          time.Duration
+	extPTTActiveLow           bool
+	denyOutOfBandTX           bool
+	splitTXCheck              bool
+	minTXVoltage              float64
+	maxTXSWR                  float64
 )
 
 func parseArgs() {
 	h := getopt.BoolLong("help", 'h', "display help")
+	ver := getopt.BoolLong("version", 0, "Display version and build info")
 	v := getopt.BoolLong("verbose", 'v', "Enable verbose (debug) logging")
 	q := getopt.BoolLong("quiet", 'q', "Disable logging")
+	hl := getopt.BoolLong("headless", 0, "Run without a terminal (no keyboard and colors, JSON logs, status logged every log interval)")
 	a := getopt.StringLong("address", 'a', "IC-705", "Connect to address")
 	u := getopt.StringLong("username", 'u', "beer", "Username")
 	p := getopt.StringLong("password", 'p', "beerbeer", "Password")
 	c := getopt.StringLong("civ-address", 'c', "0xa4", "CI-V address for radio")
 	t := getopt.Uint16Long("serial-tcp-port", 't', 4531, "Expose radio's serial port on this TCP port")
+	k := getopt.Uint16Long("serial-tcp-keepalive", 'k', 30, "TCP keepalive period in seconds for serial port clients, 0 to disable")
 	s := getopt.BoolLong("enable-serial-device", 's', "Expose radio's serial port as a virtual serial port")
 	r := getopt.Uint16Long("rigctld-port", 'r', 4532, "Use this TCP port for the internal rigctld")
+	cp := getopt.Uint16Long("civ-cmd-port", 0, 0, "Use this TCP port for injecting CI-V commands, 0 to disable")
 	e := getopt.StringLong("exec", 'e', "", "Exec cmd when connected")
 	o := getopt.StringLong("exec-serial", 'o', "socat /tmp/kappanhang-IC-705.pty /tmp/vmware.pty", "Exec cmd when virtual serial port is created, set to - to disable")
 	i := getopt.Uint16Long("log-interval", 'i', 150, "Status bar/log interval in milliseconds")
 	d := getopt.BoolLong("set-data-tx", 'd', "Automatically enable data mode on TX")
 	dp := getopt.BoolLong("debug-packets", 'D', "Show CI-V packets for debugging")
 	ca := getopt.StringLong("controller-address", 'z', "0xe0", "Controller address")
+	rl := getopt.BoolLong("swr-return-loss", 0, "Display SWR as return loss in dB")
+	sf := getopt.StringLong("spots-file", 0, "kappanhang-spots.txt", "Store spots in this file")
+	bv := getopt.BoolLong("show-both-vfos", 0, "Always display both VFO frequencies and modes")
+	um := getopt.StringLong("unknown-mode", 0, "show", "When the radio reports an unknown mode: show its code, or keep the last known mode")
+	fw := getopt.StringLong("filter-widths", 0, "", "FIL1/FIL2/FIL3 widths in Hz set with the i hotkey, as mode=Hz/Hz/Hz pairs (for example USB=3000/2400/1800)")
+	mts := getopt.StringLong("mode-tuning-steps", 0, "", "Set the tuning step on mode change, as mode=Hz pairs (for example CW=100,FM=12500)")
+	crf := getopt.StringLong("cw-rtty-filter", 0, "", "Select this filter (FIL1, FIL2 or FIL3) when switching to CW/RTTY")
+	tc := getopt.StringLong("tts", 0, "", "Speak frequency, mode and S level changes using this TTS cmd (text is piped to its stdin)")
+	bem := getopt.Uint16Long("band-edge-margin", 0, 10, "Warn when closer to a band edge than this many kHz, 0 to disable")
+	mtp := getopt.Uint16Long("max-tune-power", 0, 0, "Lower TX power to this percent while tuning, 0 to disable")
+	tt := getopt.Uint16Long("tune-timeout", 0, 30, "Abort antenna tuning after this many seconds, 0 to disable")
+	ms := getopt.BoolLong("monitor-squelch", 0, "Mute the local audio monitor while the radio's squelch is closed")
+	sl := getopt.StringLong("session-log", 0, "", "Append band changes, long transmits and stored spots to this file")
+	asi := getopt.Uint16Long("autosave-interval", 0, 10, "Write the session log to disk every this many seconds")
+	ep := getopt.StringLong("ext-ptt", 0, "", "Drive PTT from this GPIO value file or named pipe (1/0 levels)")
+	epl := getopt.BoolLong("ext-ptt-active-low", 0, "The external PTT input is active low")
+	dob := getopt.BoolLong("deny-out-of-band-tx", 0, "Don't transmit outside of the known bands")
+	stc := getopt.BoolLong("split-tx-check", 0, "Re-read the sub VFO before keying in split and show the TX frequency")
+	mtv := getopt.StringLong("min-tx-voltage", 0, "0", "Don't transmit if the last voltage reading is below this, 0 to disable")
+	mxs := getopt.StringLong("max-tx-swr", 0, "0", "Don't transmit if the last SWR reading on the band is above this, 0 to disable")
+	qf := getopt.StringLong("quick-freqs", 0, "", "Frequencies of band+mode shortcuts for frequency entry, as pairs like 40cw=7025000")
+	bef := getopt.StringLong("band-entry-freqs", 0, "", "Land on these frequencies when changing bands, as band=Hz pairs (for example 20m=14285000)")
+	sb := getopt.BoolLong("show-band", 0, "Display the current band name (20m, 2m etc.)")
+	sl1 := getopt.StringLong("status-line1", 0, strings.Join(statusLine1Fields, ","), "Fields of the first status bar line")
+	sl2 := getopt.StringLong("status-line2", 0, strings.Join(statusLine2Fields, ","), "Fields of the second status bar line")
+	sdp := getopt.StringLong("serial-device-path", 0, "", "Path of the virtual serial port (default /tmp/kappanhang-<radio name>.pty)")
+	ef := getopt.StringLong("exec-fail", 0, "retry", "What to do if an exec cmd fails: retry, stop or exit")
+	sc := getopt.StringLong("state-csv", 0, "", "Append state changes to this CSV file")
+	sj := getopt.StringLong("status-json", 0, "", "Append the status as JSON lines to this file")
+	sji := getopt.Uint16Long("status-json-interval", 0, 1000, "Status JSON emit interval in milliseconds")
+	sjd := getopt.BoolLong("status-json-delta", 0, "Only emit status JSON when a value changes")
+	cg := getopt.Uint16Long("civ-cmd-gap", 0, 0, "Min. time between sent CI-V commands in milliseconds, 0 to disable")
+	msd := getopt.StringLong("max-session", 0, "", "Disconnect after this duration (like 90m or 2h), with a warning before")
+	ipa := getopt.Uint16Long("idle-poll-after", 0, 0, "Poll meters less often after this many seconds without a keypress, 0 to disable")
+	ipi := getopt.Uint16Long("idle-poll-interval", 0, 5, "Meter poll interval in seconds when idle")
+	wf := getopt.StringLong("watch-freqs", 0, "", "Highlight the frequency when on one of these, as name=Hz pairs (for example FT8=14074000)")
+	wft := getopt.Uint16Long("watch-freq-tolerance", 0, 500, "Tolerance of the watched frequencies in Hz")
+	tst := getopt.StringLong("time-stations", 0, "WWV=2500000,WWV=5000000,WWV=10000000,WWV=15000000,WWV=20000000,CHU=3330000,CHU=7850000,CHU=14670000",
+		"Time stations to cycle through with the W hotkey, as name=Hz pairs")
+	hm := getopt.StringLong("home", 0, "", "Home frequency in Hz and optionally mode, for example 14074000,USB")
+	smc := getopt.StringLong("s-meter-cal", 0, "", "Load S meter calibration table from this file")
+	poe := getopt.BoolLong("power-off-on-exit", 0, "Power off the radio when exiting")
+	rps := getopt.BoolLong("radio-power-save", 0, "Turn down the radio's backlight and turn off its scope while connected")
+	ta := getopt.Uint16Long("tuning-accel", 0, 10, "Max. tuning step multiplier when a tuning key is held, 1 to disable")
+	asr := getopt.IntLong("audio-sample-rate", 0, 48000, "Audio sample rate (8000, 16000, 24000 or 48000)")
+	arb := getopt.Uint16Long("audio-rx-buffer", 0, 100, "Audio RX buffer length in milliseconds")
+	atb := getopt.Uint16Long("audio-tx-buffer", 0, 300, "Audio TX buffer length in milliseconds, max. 500")
+	ast := getopt.Uint16Long("audio-stall-timeout", 0, 0, "Restart the audio stream if no audio is received for this many milliseconds, 0 to disable")
+	thp := getopt.Uint16Long("tx-highpass", 0, 0, "High-pass filter the recorded TX audio at this Hz, 0 to disable")
+	tpe := getopt.BoolLong("tx-preemphasis", 0, "Apply pre-emphasis to the recorded TX audio")
+	tcr := getopt.Uint16Long("tx-compress", 0, 0, "Compress the recorded TX audio with this ratio (2-10), 0 to disable")
+	rag := getopt.BoolLong("rx-agc", 0, "Normalize the volume of the audio played on the default sound device")
+	raa := getopt.Uint16Long("rx-agc-attack", 0, 10, "RX audio AGC attack time in milliseconds")
+	rar := getopt.Uint16Long("rx-agc-release", 0, 500, "RX audio AGC release time in milliseconds")
 
 	getopt.Parse()
 
+	if *ver {
+		fmt.Println(getVersionStr())
+		os.Exit(0)
+	}
+
 	if *h || *a == "" || (*q && *v) {
 		fmt.Println(getAboutStr())
 		getopt.Usage()
@@ -56,34 +163,466 @@ func parseArgs() {
 
 	verboseLog = *v
 	quietLog = *q
+	headless = *hl
 	connectAddress = *a
 	username = *u
 	password = *p
 
-	*c = strings.Replace(*c, "0x", "", -1)
-	*c = strings.Replace(*c, "0X", "", -1)
-	civAddressInt, err := strconv.ParseInt(*c, 16, 64)
+	var err error
+	civAddress, err = parseCIVAddress(*c)
 	if err != nil {
 		fmt.Println("invalid CI-V address: can't parse", *c)
 		os.Exit(1)
 	}
-	civAddress = byte(civAddressInt)
 
-	*ca = strings.Replace(*ca, "0x", "", -1)
-	*ca = strings.Replace(*ca, "0X", "", -1)
-	controllerAddressInt, err := strconv.ParseInt(*ca, 16, 64)
+	controllerAddress, err = parseCIVAddress(*ca)
 	if err != nil {
 		fmt.Println("invalid CI-V address for controller: can't parse", *ca)
 		os.Exit(1)
 	}
-	controllerAddress = byte(controllerAddressInt)
+
+	switch *um {
+	case "show":
+	case "keep":
+		keepModeOnUnknown = true
+	default:
+		fmt.Println("invalid unknown mode setting:", *um)
+		os.Exit(1)
+	}
+
+	if civModeFilterWidths, err = parseModeFilterWidths(*fw); err != nil {
+		fmt.Println("invalid filter widths:", err)
+		os.Exit(1)
+	}
+
+	if *mts != "" {
+		civModeTuningSteps, err = parseModeTuningSteps(*mts)
+		if err != nil {
+			fmt.Println("invalid mode tuning steps:", err)
+			os.Exit(1)
+		}
+	}
+
+	cwRTTYFilterIdx = -1
+	if *crf != "" {
+		for i := range civFilters {
+			if strings.EqualFold(civFilters[i].name, *crf) {
+				cwRTTYFilterIdx = i
+			}
+		}
+		if cwRTTYFilterIdx < 0 {
+			fmt.Println("invalid cw/rtty filter:", *crf)
+			os.Exit(1)
+		}
+	}
+
+	var sampleRateOk bool
+	for _, r := range audioSampleRates {
+		if *asr == r {
+			sampleRateOk = true
+		}
+	}
+	if !sampleRateOk {
+		fmt.Println("invalid audio sample rate:", *asr)
+		os.Exit(1)
+	}
+	if *arb == 0 {
+		fmt.Println("invalid audio rx buffer length: can't be 0")
+		os.Exit(1)
+	}
+	txSeqBufLength = time.Duration(*atb) * time.Millisecond
+	if txSeqBufLength == 0 || txSeqBufLength > maxTxSeqBufLength {
+		fmt.Println("invalid audio tx buffer length:", *atb)
+		os.Exit(1)
+	}
+	audioRxSeqBufLength = time.Duration(*arb) * time.Millisecond
+	audioStallTimeout = time.Duration(*ast) * time.Millisecond
+	if audioStallTimeout >= audioTimeoutDuration {
+		fmt.Println("invalid audio stall timeout: must be less than", audioTimeoutDuration.Milliseconds())
+		os.Exit(1)
+	}
+	setAudioSampleRate(*asr)
+
+	if *tcr > 10 {
+		fmt.Println("invalid tx compression ratio:", *tcr)
+		os.Exit(1)
+	}
+	txAudioHighPassFreq = uint(*thp)
+	txAudioPreEmphasis = *tpe
+	txAudioCompressRatio = uint(*tcr)
+	if *raa == 0 || *rar == 0 {
+		fmt.Println("invalid rx agc attack/release time: can't be 0")
+		os.Exit(1)
+	}
+	rxAudioAGCEnabled = *rag
+	rxAudioAGCAttack = time.Duration(*raa) * time.Millisecond
+	rxAudioAGCRelease = time.Duration(*rar) * time.Millisecond
 
 	serialTCPPort = *t
+	serialTCPKeepAlive = time.Duration(*k) * time.Second
 	enableSerialDevice = *s
 	rigctldPort = *r
+	civCmdPort = *cp
 	runCmd = *e
 	runCmdOnSerialPortCreated = *o
 	statusLogInterval = time.Duration(*i) * time.Millisecond
+	if headless && !getopt.IsSet("log-interval") {
+		// The default interval is for the status bar, logging the status that often would flood the logs.
+		statusLogInterval = time.Second
+	}
 	setDataModeOnTx = *d
 	debugPackets = *dp
+	swrAsReturnLoss = *rl
+	spotsFile = *sf
+	showBothVFOs = *bv
+	showBand = *sb
+	monitorSquelch = *ms
+	extPTTPath = *ep
+	sessionLogFile = *sl
+	if *asi == 0 {
+		fmt.Println("invalid autosave interval: can't be 0")
+		os.Exit(1)
+	}
+	autoSaveInterval = time.Duration(*asi) * time.Second
+	extPTTActiveLow = *epl
+	denyOutOfBandTX = *dob
+	splitTXCheck = *stc
+	if minTXVoltage, err = strconv.ParseFloat(*mtv, 64); err != nil || minTXVoltage < 0 {
+		fmt.Println("invalid min tx voltage:", *mtv)
+		os.Exit(1)
+	}
+	if maxTXSWR, err = strconv.ParseFloat(*mxs, 64); err != nil || (maxTXSWR != 0 && maxTXSWR < 1) {
+		fmt.Println("invalid max tx swr:", *mxs)
+		os.Exit(1)
+	}
+	ttsCmd = *tc
+	bandEdgeMargin = uint64(*bem) * 1000
+	if *mtp > 100 {
+		fmt.Println("invalid max tune power:", *mtp)
+		os.Exit(1)
+	}
+	maxTunePwrLevel = int(*mtp) * 0xff / 100
+	tuneTimeout = time.Duration(*tt) * time.Second
+	powerOffOnExit = *poe
+	radioPowerSaveEnabled = *rps
+	civCmdGap = time.Duration(*cg) * time.Millisecond
+	idlePollAfter = time.Duration(*ipa) * time.Second
+	if *msd != "" {
+		if maxSessionDuration, err = time.ParseDuration(*msd); err != nil || maxSessionDuration <= 0 {
+			fmt.Println("invalid max session duration:", *msd)
+			os.Exit(1)
+		}
+	}
+	if *ipi == 0 {
+		fmt.Println("invalid idle poll interval: can't be 0")
+		os.Exit(1)
+	}
+	idlePollInterval = time.Duration(*ipi) * time.Second
+	stateCSVFile = *sc
+	statusJSONFile = *sj
+	if *sji == 0 {
+		fmt.Println("invalid status json interval:", *sji)
+		os.Exit(1)
+	}
+	statusJSONInterval = time.Duration(*sji) * time.Millisecond
+	statusJSONDelta = *sjd
+	execFailPolicy = *ef
+	serialDevicePath = *sdp
+	if execFailPolicy != "retry" && execFailPolicy != "stop" && execFailPolicy != "exit" {
+		fmt.Println("invalid exec fail policy:", execFailPolicy)
+		os.Exit(1)
+	}
+	knownStatusFields := append(append(append([]string{}, statusLine1Fields...), statusLine2Fields...),
+		statusOptionalFields...)
+	statusLine1Fields, err = parseStatusFields(*sl1, knownStatusFields)
+	if err != nil {
+		fmt.Println("invalid status line 1 fields:", err)
+		os.Exit(1)
+	}
+	statusLine2Fields, err = parseStatusFields(*sl2, knownStatusFields)
+	if err != nil {
+		fmt.Println("invalid status line 2 fields:", err)
+		os.Exit(1)
+	}
+	homeModeIdx = -1
+	if *hm != "" {
+		homeFreq, homeModeIdx, err = parseHome(*hm)
+		if err != nil {
+			fmt.Println("invalid home:", err)
+			os.Exit(1)
+		}
+	}
+	if err := parseQuickFreqs(*qf); err != nil {
+		fmt.Println("invalid quick frequencies:", err)
+		os.Exit(1)
+	}
+	if err := parseBandEntryFreqs(*bef); err != nil {
+		fmt.Println("invalid band entry frequencies:", err)
+		os.Exit(1)
+	}
+	timeStations, err = parseTimeStations(*tst)
+	if err != nil {
+		fmt.Println("invalid time stations:", err)
+		os.Exit(1)
+	}
+	watchedFreqs, err = parseWatchedFreqs(*wf)
+	if err != nil {
+		fmt.Println("invalid watched frequencies:", err)
+		os.Exit(1)
+	}
+	watchedFreqTolerance = uint64(*wft)
+	if *smc != "" {
+		if err := sMeter.loadCal(*smc); err != nil {
+			fmt.Println("can't load S meter calibration:", err)
+			os.Exit(1)
+		}
+	}
+	maxTuningAccel = uint(*ta)
+	if maxTuningAccel < 1 {
+		maxTuningAccel = 1
+	}
+}
+
+// Parses a hexadecimal CI-V address, with or without the 0x prefix.
+func parseCIVAddress(str string) (byte, error) {
+	str = strings.TrimSpace(str)
+	str = strings.Replace(str, "0x", "", -1)
+	str = strings.Replace(str, "0X", "", -1)
+	a, err := strconv.ParseUint(str, 16, 8)
+	if err != nil {
+		return 0, err
+	}
+	return byte(a), nil
+}
+
+// Parses a comma separated list of status bar field names.
+func parseStatusFields(str string, known []string) (res []string, err error) {
+	for _, name := range strings.Split(str, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		var found bool
+		for _, k := range known {
+			if k == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %s", name)
+		}
+		res = append(res, name)
+	}
+	return res, nil
+}
+
+// Parses a frequency in Hz, optionally followed by a comma and an operating mode.
+func parseHome(str string) (freq uint64, modeIdx int, err error) {
+	split := strings.Split(str, ",")
+	f, err := strconv.ParseUint(strings.TrimSpace(split[0]), 10, 64)
+	if err != nil || f == 0 {
+		return 0, -1, fmt.Errorf("can't parse frequency %s", split[0])
+	}
+	modeIdx = -1
+	if len(split) > 1 {
+		mode := strings.ToUpper(strings.TrimSpace(split[1]))
+		for i := range civOperatingModes {
+			if civOperatingModes[i].name == mode {
+				modeIdx = i
+				break
+			}
+		}
+		if modeIdx < 0 {
+			return 0, -1, fmt.Errorf("unknown mode %s", mode)
+		}
+	}
+	return f, modeIdx, nil
+}
+
+// Parses a list of band=Hz pairs separated by commas, and sets the entry frequencies of the bands.
+func parseBandEntryFreqs(str string) error {
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return fmt.Errorf("can't parse %s", pair)
+		}
+
+		band := strings.TrimSpace(pairSplit[0])
+		bandIdx := -1
+		for i := range civBands {
+			if strings.EqualFold(civBands[i].name, band) {
+				bandIdx = i
+				break
+			}
+		}
+		if bandIdx < 0 {
+			return fmt.Errorf("unknown band %s", band)
+		}
+
+		f, err := strconv.ParseUint(strings.TrimSpace(pairSplit[1]), 10, 64)
+		if err != nil || f < civBands[bandIdx].freqFrom || f > civBands[bandIdx].freqTo {
+			return fmt.Errorf("invalid frequency %s for band %s", pairSplit[1], band)
+		}
+		civBands[bandIdx].entryFreq = f
+	}
+	return nil
+}
+
+// Parses a list of shortcut=Hz pairs separated by commas (for example 40cw=7025000), and sets the
+// frequencies of the band and mode shortcuts.
+func parseQuickFreqs(str string) error {
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return fmt.Errorf("can't parse %s", pair)
+		}
+
+		bandIdx, mode, err := parseQuickFreqShortcut(pairSplit[0])
+		if err != nil {
+			return err
+		}
+
+		f, err := strconv.ParseUint(strings.TrimSpace(pairSplit[1]), 10, 64)
+		if err != nil || f < civBands[bandIdx].freqFrom || f > civBands[bandIdx].freqTo {
+			return fmt.Errorf("invalid frequency %s for %s", pairSplit[1], pairSplit[0])
+		}
+		quickFreqs[quickFreqKey(bandIdx, mode)] = f
+	}
+	return nil
+}
+
+// Parses a list of name=Hz pairs separated by commas.
+func parseTimeStations(str string) (res []timeStation, err error) {
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return nil, fmt.Errorf("can't parse %s", pair)
+		}
+		f, err := strconv.ParseUint(strings.TrimSpace(pairSplit[1]), 10, 64)
+		minFreq, maxFreq := getFreqRange()
+		if err != nil || f < minFreq || f > maxFreq {
+			return nil, fmt.Errorf("invalid frequency %s", pairSplit[1])
+		}
+		res = append(res, timeStation{name: strings.TrimSpace(pairSplit[0]), freq: f})
+	}
+	return
+}
+
+// Parses a list of name=Hz pairs separated by commas.
+func parseWatchedFreqs(str string) (res []watchedFreq, err error) {
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return nil, fmt.Errorf("can't parse %s", pair)
+		}
+		f, err := strconv.ParseUint(strings.TrimSpace(pairSplit[1]), 10, 64)
+		minFreq, maxFreq := getFreqRange()
+		if err != nil || f < minFreq || f > maxFreq {
+			return nil, fmt.Errorf("invalid frequency %s", pairSplit[1])
+		}
+		res = append(res, watchedFreq{name: strings.TrimSpace(pairSplit[0]), freq: f})
+	}
+	return
+}
+
+// Parses filter widths in Hz separated by slashes (for example 3000/2400/1800).
+func parseFilterWidths(str string) (res []int, err error) {
+	for _, w := range strings.Split(str, "/") {
+		hz, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil {
+			return nil, fmt.Errorf("can't parse filter width %s", w)
+		}
+		res = append(res, hz)
+	}
+	if len(res) != len(civFilters) {
+		return nil, fmt.Errorf("%d filter widths are needed", len(civFilters))
+	}
+	return res, nil
+}
+
+// Parses a list of mode=Hz/Hz/Hz pairs separated by commas.
+func parseModeFilterWidths(str string) (res map[string][]int, err error) {
+	res = make(map[string][]int)
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return nil, fmt.Errorf("can't parse %s", pair)
+		}
+
+		mode := strings.ToUpper(strings.TrimSpace(pairSplit[0]))
+		var modeFound bool
+		for i := range civOperatingModes {
+			if civOperatingModes[i].name == mode {
+				modeFound = true
+				break
+			}
+		}
+		if !modeFound {
+			return nil, fmt.Errorf("unknown mode %s", mode)
+		}
+
+		if res[mode], err = parseFilterWidths(pairSplit[1]); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Parses a list of mode=Hz pairs separated by commas to tuning step CI-V codes.
+func parseModeTuningSteps(str string) (res map[string]byte, err error) {
+	res = make(map[string]byte)
+	for _, pair := range strings.Split(str, ",") {
+		if pair == "" {
+			continue
+		}
+		pairSplit := strings.Split(pair, "=")
+		if len(pairSplit) != 2 {
+			return nil, fmt.Errorf("can't parse %s", pair)
+		}
+
+		mode := strings.ToUpper(strings.TrimSpace(pairSplit[0]))
+		var modeFound bool
+		for i := range civOperatingModes {
+			if civOperatingModes[i].name == mode {
+				modeFound = true
+				break
+			}
+		}
+		if !modeFound {
+			return nil, fmt.Errorf("unknown mode %s", mode)
+		}
+
+		hz, err := strconv.ParseUint(strings.TrimSpace(pairSplit[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse tuning step %s", pairSplit[1])
+		}
+		codeFound := false
+		for code, ts := range civTuningSteps {
+			if uint(hz) == ts {
+				res[mode] = byte(code)
+				codeFound = true
+				break
+			}
+		}
+		if !codeFound {
+			return nil, fmt.Errorf("unsupported tuning step %d", hz)
+		}
+	}
+	return res, nil
 }
@@ -15,12 +15,21 @@ import (
 	"github.com/mesilliac/pulse-simple"
 )
 
-const audioSampleRate = 48000
 const audioSampleBytes = 2
 const pulseAudioBufferLength = 100 * time.Millisecond
 const audioFrameLength = 20 * time.Millisecond
-const audioFrameSize = int((audioSampleRate * audioSampleBytes * audioFrameLength) / time.Second)
-const maxPlayBufferSize = audioFrameSize*5 + int((audioSampleRate*audioSampleBytes*audioRxSeqBufLength)/time.Second)
+
+// These depend on command line arguments, so they are calculated by setAudioSampleRate().
+var audioSampleRate int
+var audioFrameSize int
+var maxPlayBufferSize int
+
+func setAudioSampleRate(rate int) {
+	audioSampleRate = rate
+	bytesPerSec := time.Duration(audioSampleRate * audioSampleBytes)
+	audioFrameSize = int(bytesPerSec * audioFrameLength / time.Second)
+	maxPlayBufferSize = audioFrameSize*5 + int(bytesPerSec*audioRxSeqBufLength/time.Second)
+}
 
 type audioStruct struct {
 	devName string
@@ -49,6 +58,8 @@ type audioStruct struct {
 
 		recLoopDeinitNeededChan   chan bool
 		recLoopDeinitFinishedChan chan bool
+		// Rec is turned on and off by the space hotkey and the external PTT input.
+		recMutex sync.Mutex
 
 		mutex   sync.Mutex
 		playBuf *bytes.Buffer
@@ -87,8 +98,23 @@ func (a *audioStruct) togglePlaybackToDefaultSoundcard() {
 }
 
 func (a *audioStruct) toggleRecFromDefaultSoundcard() {
-	if a.defaultSoundcardStream.recStream == nil {
-		ss := pulse.SampleSpec{Format: pulse.SAMPLE_S16LE, Rate: audioSampleRate, Channels: 1}
+	a.defaultSoundcardStream.recMutex.Lock()
+	on := a.defaultSoundcardStream.recStream == nil
+	a.defaultSoundcardStream.recMutex.Unlock()
+
+	a.setRecFromDefaultSoundcard(on)
+}
+
+// Turns on audio rec from the default soundcard with PTT, or turns both off.
+func (a *audioStruct) setRecFromDefaultSoundcard(on bool) {
+	a.defaultSoundcardStream.recMutex.Lock()
+	defer a.defaultSoundcardStream.recMutex.Unlock()
+
+	if on == (a.defaultSoundcardStream.recStream != nil) {
+		return
+	}
+	if on {
+		ss := pulse.SampleSpec{Format: pulse.SAMPLE_S16LE, Rate: uint32(audioSampleRate), Channels: 1}
 		battr := pulse.NewBufferAttr()
 		battr.Fragsize = uint32(audioFrameSize)
 		var err error
@@ -127,7 +153,7 @@ func (a *audioStruct) doTogglePlaybackToDefaultSoundcard() {
 	if a.defaultSoundcardStream.playStream == nil {
 		log.Print("turned on audio playback")
 		statusLog.reportAudioMon(true)
-		ss := pulse.SampleSpec{Format: pulse.SAMPLE_S16LE, Rate: audioSampleRate, Channels: 1}
+		ss := pulse.SampleSpec{Format: pulse.SAMPLE_S16LE, Rate: uint32(audioSampleRate), Channels: 1}
 		a.defaultSoundcardStream.playStream, _ = pulse.Playback("kappanhang", a.devName, &ss)
 	} else {
 		a.defaultSoundCardPlayStreamDeinit()
@@ -166,6 +192,13 @@ func (a *audioStruct) playLoopToDefaultSoundcard(deinitNeededChan, deinitFinishe
 				break
 			}
 
+			// Silence is played instead of dropping the frame, so the playback stream won't underrun.
+			if monitorSquelch && civControl.isSquelchClosed() {
+				d = make([]byte, len(d))
+			} else if rxAudioAGCEnabled {
+				rxAudioAGC.process(d)
+			}
+
 			for len(d) > 0 && a.defaultSoundcardStream.playStream != nil {
 				written, err := a.defaultSoundcardStream.playStream.Write(d)
 				if err != nil {
@@ -218,6 +251,12 @@ func (a *audioStruct) recLoopFromDefaultSoundcard() {
 			if n != len(frameBuf) {
 				reportError(errors.New("audio buffer read error"))
 			}
+			if dtmfSender.isSending() {
+				continue
+			}
+			if txAudioProc.isEnabled() {
+				txAudioProc.process(b)
+			}
 
 			select {
 			case a.rec <- b:
@@ -312,6 +351,9 @@ func (a *audioStruct) recLoopFromVirtualSoundcard(deinitNeededChan, deinitFinish
 			if n != len(frameBuf) {
 				reportError(errors.New("audio buffer read error"))
 			}
+			if dtmfSender.isSending() {
+				continue
+			}
 
 			select {
 			case a.rec <- b:
@@ -362,6 +404,7 @@ func (a *audioStruct) loop() {
 		if free < len(d) {
 			b := make([]byte, len(d)-free)
 			_, _ = a.virtualSoundcardStream.playBuf.Read(b)
+			netstat.reportAudioOverrun()
 		}
 		a.virtualSoundcardStream.playBuf.Write(d)
 		a.virtualSoundcardStream.mutex.Unlock()
@@ -399,7 +442,7 @@ func (a *audioStruct) loop() {
 //	so it may be desirable to enable force cleanup and recreate via flags
 func (a *audioStruct) initIfNeeded(devName string) error {
 	a.devName = devName
-	bufferSizeInBits := (audioSampleRate * audioSampleBytes * 8) / 1000 * pulseAudioBufferLength.Milliseconds()
+	bufferSizeInBits := int64(audioSampleRate*audioSampleBytes*8) / 1000 * pulseAudioBufferLength.Milliseconds()
 
 	if !a.virtualSoundcardStream.source.IsOpen() {
 		a.virtualSoundcardStream.source.Name = "kappanhang-" + a.devName
@@ -9,7 +9,21 @@ import (
 )
 
 const audioTimeoutDuration = 5 * time.Second
-const audioRxSeqBufLength = 100 * time.Millisecond
+const audioMaxPartSize = 1364
+
+// The smallest audio packet contains 10ms of audio at the lowest sample rate.
+const audioMinPacketLength = 24 + 160
+
+// The sample rates the transceiver accepts for 16 bit LPCM audio.
+var audioSampleRates = []int{8000, 16000, 24000, 48000}
+
+// It can be set with a command line argument.
+var audioRxSeqBufLength = 100 * time.Millisecond
+
+// If no audio packets are received for this long, then only the audio stream is restarted. It's
+// disabled if 0, and should be shorter than audioTimeoutDuration, after which the whole connection is
+// restarted. It can be set with a command line argument.
+var audioStallTimeout time.Duration
 
 type audioStream struct {
 	common streamCommon
@@ -17,10 +31,18 @@ type audioStream struct {
 	deinitNeededChan   chan bool
 	deinitFinishedChan chan bool
 
-	timeoutTimer    *time.Timer
+	devName string
+
+	timeoutTimer *time.Timer
+	// The audio timeout deadline is kept across audio stream restarts, so a stalled audio stream
+	// can't be restarted forever, the whole connection is restarted after audioTimeoutDuration.
+	timeoutDeadline time.Time
+	stallTimer      *time.Timer
+	stalledChan     chan bool // signals the control stream that the audio stream should be restarted
 	receivedAudio   bool
 	lastReceivedSeq uint16
 	serverAudioTime time.Time
+	lastEntryAt     time.Time
 
 	rxSeqBuf          seqBuf
 	rxSeqBufEntryChan chan seqBufEntry
@@ -28,24 +50,10 @@ type audioStream struct {
 	audioSendSeq uint16
 }
 
-// sendPart1 expects 1364 bytes of PCM data.
-func (s *audioStream) sendPart1(pcmData []byte) error {
-	err := s.common.pkt0.sendTrackedPacket(&s.common,
-		append([]byte{0x6c, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
-			byte(s.common.localSID >> 24), byte(s.common.localSID >> 16), byte(s.common.localSID >> 8), byte(s.common.localSID),
-			byte(s.common.remoteSID >> 24), byte(s.common.remoteSID >> 16), byte(s.common.remoteSID >> 8), byte(s.common.remoteSID),
-			0x80, 0x00, byte((s.audioSendSeq - 1) >> 8), byte(s.audioSendSeq - 1), 0x00, 0x00, byte(len(pcmData) >> 8), byte(len(pcmData))},
-			pcmData...))
-	if err != nil {
-		return err
-	}
-	s.audioSendSeq++
-	return nil
-}
-
-// sendPart2 expects 556 bytes of PCM data.
-func (s *audioStream) sendPart2(pcmData []byte) error {
-	err := s.common.pkt0.sendTrackedPacket(&s.common, append([]byte{0x44, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+// sendPart expects at most audioMaxPartSize bytes of PCM data.
+func (s *audioStream) sendPart(pcmData []byte) error {
+	l := 24 + len(pcmData)
+	err := s.common.pkt0.sendTrackedPacket(&s.common, append([]byte{byte(l), byte(l >> 8), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
 		byte(s.common.localSID >> 24), byte(s.common.localSID >> 16), byte(s.common.localSID >> 8), byte(s.common.localSID),
 		byte(s.common.remoteSID >> 24), byte(s.common.remoteSID >> 16), byte(s.common.remoteSID >> 8), byte(s.common.remoteSID),
 		0x80, 0x00, byte((s.audioSendSeq - 1) >> 8), byte(s.audioSendSeq - 1), 0x00, 0x00, byte(len(pcmData) >> 8), byte(len(pcmData))},
@@ -57,6 +65,22 @@ func (s *audioStream) sendPart2(pcmData []byte) error {
 	return nil
 }
 
+// Audio frames are sent in parts of max. audioMaxPartSize bytes. With 48kHz sample rate a frame
+// is sent as a 1364 and a 556 bytes long part.
+func (s *audioStream) sendFrame(d []byte) error {
+	for len(d) > 0 {
+		n := len(d)
+		if n > audioMaxPartSize {
+			n = audioMaxPartSize
+		}
+		if err := s.sendPart(d[:n]); err != nil {
+			return err
+		}
+		d = d[n:]
+	}
+	return nil
+}
+
 func (s *audioStream) handleRxSeqBufEntry(e seqBufEntry) {
 	gotSeq := uint16(e.seq)
 	if s.receivedAudio {
@@ -79,11 +103,24 @@ func (s *audioStream) handleRxSeqBufEntry(e seqBufEntry) {
 			s.serverAudioTime = s.serverAudioTime.Add(time.Duration(10*missingPkts) * time.Millisecond)
 		}
 		s.serverAudioTime = s.serverAudioTime.Add(10 * time.Millisecond)
+
+		// If no audio was played for longer than the buffer length, then the buffer ran dry.
+		if time.Since(s.lastEntryAt) > audioRxSeqBufLength {
+			netstat.reportAudioUnderrun()
+		}
 	} else {
 		s.serverAudioTime = time.Now()
 	}
 	s.lastReceivedSeq = gotSeq
 	s.receivedAudio = true
+	s.lastEntryAt = time.Now()
+
+	bytesPerMs := audioSampleRate * audioSampleBytes / 1000
+	netstat.reportAudioBufDepth(time.Duration(s.rxSeqBuf.getDataLength()/bytesPerMs) * time.Millisecond)
+
+	if civControl.isFMMode() {
+		dtmfDetector.process(e.data)
+	}
 
 	audio.play <- e.data
 }
@@ -93,19 +130,31 @@ func (s *audioStream) handleAudioPacket(r []byte) error {
 	if s.timeoutTimer != nil {
 		s.timeoutTimer.Stop()
 		s.timeoutTimer.Reset(audioTimeoutDuration)
+		s.timeoutDeadline = time.Now().Add(audioTimeoutDuration)
+	}
+	if s.stallTimer != nil {
+		s.stallTimer.Stop()
+		s.stallTimer.Reset(audioStallTimeout)
 	}
 
 	return s.rxSeqBuf.add(seqNum(gotSeq), r[24:])
 }
 
 func (s *audioStream) handleRead(r []byte) error {
-	if len(r) >= 580 && (bytes.Equal(r[:6], []byte{0x6c, 0x05, 0x00, 0x00, 0x00, 0x00}) || bytes.Equal(r[:6], []byte{0x44, 0x02, 0x00, 0x00, 0x00, 0x00})) {
+	// Audio packets start with their length.
+	if len(r) >= audioMinPacketLength && int(binary.LittleEndian.Uint16(r[:2])) == len(r) &&
+		bytes.Equal(r[2:6], []byte{0x00, 0x00, 0x00, 0x00}) {
 		return s.handleAudioPacket(r)
 	}
 	return nil
 }
 
 func (s *audioStream) loop() {
+	var stallTimerChan <-chan time.Time
+	if s.stallTimer != nil {
+		stallTimerChan = s.stallTimer.C
+	}
+
 	for {
 		select {
 		case r := <-s.common.readChan:
@@ -115,13 +164,16 @@ func (s *audioStream) loop() {
 		case <-s.timeoutTimer.C:
 			reportError(errors.New(fmt.Sprint("audio stream timeout after ",
 				time.Since(statusLog.data.startTime), ", try rebooting the radio")))
+		case <-stallTimerChan:
+			log.Error("no audio received for ", audioStallTimeout, ", restarting the audio stream")
+			select {
+			case s.stalledChan <- true:
+			default:
+			}
 		case e := <-s.rxSeqBufEntryChan:
 			s.handleRxSeqBufEntry(e)
 		case d := <-audio.rec:
-			if err := s.sendPart1(d[:1364]); err != nil {
-				reportError(err)
-			}
-			if err := s.sendPart2(d[1364:1920]); err != nil {
+			if err := s.sendFrame(d); err != nil {
 				reportError(err)
 			}
 		case <-s.deinitNeededChan:
@@ -132,6 +184,11 @@ func (s *audioStream) loop() {
 }
 
 func (s *audioStream) init(devName string) error {
+	s.devName = devName
+	if s.stalledChan == nil {
+		s.stalledChan = make(chan bool, 1)
+	}
+
 	if err := s.common.init("audio", audioStreamPort); err != nil {
 		return err
 	}
@@ -153,7 +210,13 @@ func (s *audioStream) init(devName string) error {
 	s.rxSeqBufEntryChan = make(chan seqBufEntry)
 	s.rxSeqBuf.init(audioRxSeqBufLength, 0xffff, 0, s.rxSeqBufEntryChan, s.common.requestRetransmit)
 
-	s.timeoutTimer = time.NewTimer(audioTimeoutDuration)
+	if s.timeoutDeadline.IsZero() {
+		s.timeoutDeadline = time.Now().Add(audioTimeoutDuration)
+	}
+	s.timeoutTimer = time.NewTimer(time.Until(s.timeoutDeadline))
+	if audioStallTimeout > 0 {
+		s.stallTimer = time.NewTimer(audioStallTimeout)
+	}
 
 	s.deinitNeededChan = make(chan bool)
 	s.deinitFinishedChan = make(chan bool)
@@ -161,6 +224,17 @@ func (s *audioStream) init(devName string) error {
 	return nil
 }
 
+// Restarts only the audio stream, the control and serial streams are kept running.
+func (s *audioStream) restart() error {
+	devName := s.devName
+	stalledChan := s.stalledChan
+	timeoutDeadline := s.timeoutDeadline
+	s.deinit()
+
+	*s = audioStream{stalledChan: stalledChan, timeoutDeadline: timeoutDeadline}
+	return s.init(devName)
+}
+
 func (s *audioStream) deinit() {
 	if s.deinitNeededChan != nil {
 		s.deinitNeededChan <- true
@@ -169,6 +243,9 @@ func (s *audioStream) deinit() {
 	if s.timeoutTimer != nil {
 		s.timeoutTimer.Stop()
 	}
+	if s.stallTimer != nil {
+		s.stallTimer.Stop()
+	}
 	s.common.deinit()
 	s.rxSeqBuf.deinit()
 }
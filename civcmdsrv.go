@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The CI-V command server can be used to inject CI-V commands from the CIV map by name, with optional
+// hex data. Answers from the radio to the injected command are sent back to the client.
+//
+// Example session (using netcat):
+//
+//	getPwr
+//	sent: [fe fe a4 e0 14 0a fd]
+//	got: [fe fe e0 a4 14 0a 01 28 fd] cmd: [14] payload: [0a 01 28]
+//	setPwr 01 28
+//	sent: [fe fe a4 e0 14 0a 01 28 fd]
+//	got: [fe fe e0 a4 fb fd] OK
+//
+// Commands which are not in the CIV map can be sent with raw, followed by the cmd, the optional
+// subcmd and data in hex:
+//
+//	raw 14 0a
+//	got: [fe fe e0 a4 14 0a 01 28 fd] cmd: [14] payload: [0a 01 28]
+type civCmdSrvStruct struct {
+	listener net.Listener
+	client   net.Conn
+	mutex    sync.Mutex
+
+	clientLoopDeinitNeededChan   chan bool
+	clientLoopDeinitFinishedChan chan bool
+
+	deinitNeededChan   chan bool
+	deinitFinishedChan chan bool
+
+	// Answers are reported by the CI-V decoder, so they are written to the client in a separate
+	// goroutine to not block the decoder with network writes.
+	answerChan                   chan string
+	answerLoopDeinitNeededChan   chan bool
+	answerLoopDeinitFinishedChan chan bool
+}
+
+// Number of answers waiting to be written to the client. Further answers are dropped.
+const civCmdSrvAnswerBufferLen = 16
+
+var civCmdSrv civCmdSrvStruct
+
+func (s *civCmdSrvStruct) disconnectClient() {
+	if s.client != nil {
+		s.client.Close()
+	}
+}
+
+func (s *civCmdSrvStruct) deinitClient() {
+	if s.clientLoopDeinitNeededChan != nil {
+		s.clientLoopDeinitNeededChan <- true
+		<-s.clientLoopDeinitFinishedChan
+
+		s.clientLoopDeinitNeededChan = nil
+		s.clientLoopDeinitFinishedChan = nil
+	}
+}
+
+func (s *civCmdSrvStruct) send(a ...interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.client == nil {
+		return nil
+	}
+	str := fmt.Sprint(a...)
+	_, err := s.client.Write([]byte(str))
+	return err
+}
+
+// Called by the CI-V decoder when an answer for an injected command arrives.
+func (s *civCmdSrvStruct) reportAnswer(d []byte) {
+	select {
+	case s.answerChan <- s.formatAnswer(d):
+	default:
+	}
+}
+
+func (s *civCmdSrvStruct) formatAnswer(d []byte) string {
+	str := fmt.Sprintf("got: [% x]", d)
+	switch d[4] {
+	case OK:
+		str += " OK"
+	case NG:
+		str += " NG"
+	default:
+		str += fmt.Sprintf(" cmd: [%02x] payload: [% x]", d[4], d[5:len(d)-1])
+	}
+	return str + "\n"
+}
+
+func (s *civCmdSrvStruct) answerLoop() {
+	for {
+		select {
+		case str := <-s.answerChan:
+			_ = s.send(str)
+		case <-s.answerLoopDeinitNeededChan:
+			s.answerLoopDeinitFinishedChan <- true
+			return
+		}
+	}
+}
+
+func (s *civCmdSrvStruct) getCmdNames() (names []string) {
+	for name := range CIV {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (s *civCmdSrvStruct) parseHexData(a []string) ([]byte, error) {
+	str := strings.Join(a, "")
+	str = strings.Replace(str, "0x", "", -1)
+	str = strings.Replace(str, "0X", "", -1)
+	return hex.DecodeString(str)
+}
+
+func (s *civCmdSrvStruct) processCmd(cmd string) (close bool, err error) {
+	cmdSplit := strings.Fields(cmd)
+
+	switch {
+	case cmd == "q":
+		close = true
+	case cmd == "list":
+		err = s.send(strings.Join(s.getCmdNames(), "\n"), "\n")
+	case cmdSplit[0] == "raw":
+		var data []byte
+		data, err = s.parseHexData(cmdSplit[1:])
+		if err != nil {
+			_ = s.send("invalid hex data: ", err, "\n")
+			return false, nil
+		}
+		var answer []byte
+		answer, err = civControl.sendRawCIV(data)
+		if err != nil {
+			_ = s.send("send error: ", err, "\n")
+			return false, nil
+		}
+		err = s.send(s.formatAnswer(answer))
+	default:
+		if _, found := CIV[cmdSplit[0]]; !found {
+			_ = s.send("unknown command ", cmdSplit[0], ", use list to show available commands\n")
+			return
+		}
+		var data []byte
+		data, err = s.parseHexData(cmdSplit[1:])
+		if err != nil {
+			_ = s.send("invalid hex data: ", err, "\n")
+			return false, nil
+		}
+		var p []byte
+		p, err = civControl.injectCmd(cmdSplit[0], data)
+		if err != nil {
+			_ = s.send("send error: ", err, "\n")
+			return
+		}
+		err = s.send(fmt.Sprintf("sent: [% x]\n", p))
+	}
+	return
+}
+
+func (s *civCmdSrvStruct) clientLoop() {
+	defer func() {
+		s.client.Close()
+		log.Print("client ", s.client.RemoteAddr().String(), " disconnected")
+
+		<-s.clientLoopDeinitNeededChan
+		s.clientLoopDeinitFinishedChan <- true
+	}()
+
+	log.Print("client ", s.client.RemoteAddr().String(), " connected")
+
+	var b [128]byte
+	var lineBuf bytes.Buffer
+	for {
+		n, err := s.client.Read(b[:])
+		if err != nil {
+			break
+		}
+
+		select {
+		case <-s.clientLoopDeinitNeededChan:
+			s.clientLoopDeinitFinishedChan <- true
+			return
+		default:
+		}
+
+		lineBuf.Write(b[:n])
+		for {
+			line, err := lineBuf.ReadString('\n')
+			if err != nil {
+				// Putting back the incomplete line.
+				lineBuf.WriteString(line)
+				break
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			close, err := s.processCmd(line)
+			if err != nil {
+				log.Error(err)
+			}
+			if close {
+				return
+			}
+		}
+	}
+}
+
+func (s *civCmdSrvStruct) loop() {
+	for {
+		newClient, err := s.listener.Accept()
+
+		s.disconnectClient()
+		s.deinitClient()
+
+		s.clientLoopDeinitNeededChan = make(chan bool)
+		s.clientLoopDeinitFinishedChan = make(chan bool)
+
+		if err != nil {
+			if err != io.EOF {
+				reportError(err)
+			}
+			<-s.deinitNeededChan
+			s.deinitFinishedChan <- true
+			return
+		}
+
+		s.mutex.Lock()
+		s.client = newClient
+		s.mutex.Unlock()
+
+		go s.clientLoop()
+	}
+}
+
+func (s *civCmdSrvStruct) initIfNeeded() (err error) {
+	if s.listener != nil || civCmdPort == 0 {
+		return
+	}
+
+	s.listener, err = net.Listen("tcp", fmt.Sprint("127.0.0.1:", civCmdPort))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	log.Print("starting CI-V command server on tcp port ", civCmdPort)
+
+	s.answerChan = make(chan string, civCmdSrvAnswerBufferLen)
+	s.answerLoopDeinitNeededChan = make(chan bool)
+	s.answerLoopDeinitFinishedChan = make(chan bool)
+	go s.answerLoop()
+
+	s.deinitNeededChan = make(chan bool)
+	s.deinitFinishedChan = make(chan bool)
+	go s.loop()
+	return
+}
+
+func (s *civCmdSrvStruct) deinit() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+
+	if s.deinitNeededChan != nil {
+		s.deinitNeededChan <- true
+		<-s.deinitFinishedChan
+	}
+
+	if s.answerLoopDeinitNeededChan != nil {
+		s.answerLoopDeinitNeededChan <- true
+		<-s.answerLoopDeinitFinishedChan
+	}
+}
@@ -1,17 +1,50 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"math"
+	"strings"
 	"sync"
 	"time"
 )
 
 const statusPollInterval = time.Second
+const splitSubVFOFreqPollInterval = 250 * time.Millisecond // so rigctld clients see TX freq changes promptly
+const powerSourcePollInterval = 10 * time.Second
+const passbandPollInterval = 2 * time.Second // PBT knob positions are not sent by the radio
+const scopeStatePollInterval = 5 * time.Second
+const squelchStatusPollInterval = 200 * time.Millisecond // the local monitor is gated by this, so it should be fast
+const transceiveVFOFreqPollInterval = 10 * time.Second   // the radio sends frequency changes by itself
 const commandRetryTimeout = 500 * time.Millisecond
+const civSendQueueLen = 64 // commands waiting to be sent if --civ-cmd-gap is set
+const maxMemoryChannel = 99
+const sensitivityStep = 13             // about 5% of the 0-255 level range
+const sPeakWindow = time.Second        // signal reports use the peak S level in this window
+const twoStagePreampMaxFreq = 74800000 // above the HF/50MHz range the preamp has only one stage
+const memoryNameLength = 16
+const rawCmdTimeout = 2 * time.Second
+const selfTestTimeout = time.Second
+const selfTestTries = 3
 const pttTimeout = 10 * time.Minute // NOTE: US operators MUST legally identify at least once every ten minutes, most Tx should be much shorter than this
 
-const tuneTimeout = 30 * time.Second
+const civMinFreq = 30000     // 30kHz
+const civMaxFreq = 470000000 // 470MHz
+
+// The IC-905 works up to 10GHz, so it sends and expects 6 bytes of BCD frequency data instead of 5.
+const ic905CIVAddress = 0xac
+const ic905FreqDataLen = 6
+const defaultFreqDataLen = 5
+const ic905MinFreq = 144000000   // 144MHz
+const ic905MaxFreq = 10500000000 // 10.5GHz
+const powerOffPTTReleaseTimeout = time.Second
+
+const ritNudgeStep = 10 // Hz
+
+const txDeniedDisplayDuration = 5 * time.Second
+
+const maxCWMsgLength = 30
+const maxKeyerMemoryLength = 70
 const ON = 1
 const OFF = 0
 const OK = 0xfb
@@ -36,6 +69,53 @@ var civOperatingModes = []civOperatingMode{
 	{name: "DV", code: 0x17},
 }
 
+// Tuning step values in Hz, indexed by their CI-V code.
+var civTuningSteps = []uint{1, 100, 500, 1000, 5000, 6250, 8330, 9000, 10000, 12500, 20000, 25000, 50000, 100000}
+
+// Default tuning step CI-V codes for operating mode names, applied when the operating mode changes.
+// Can be set with a command line argument.
+var civModeTuningSteps map[string]byte
+
+// Last used squelch levels for operating mode names, restored when the operating mode changes.
+var civModeSQLLevels = make(map[string]int)
+
+// Widths of the filter slots in Hz, keyed by "mode/filter" (for example "USB/FIL2"). The radio only tells
+// the width of the selected filter, so the cache is filled as filters are used.
+var civFilterWidths = make(map[string]int)
+
+// FIL1-FIL3 widths in Hz for operating mode names, which are set on request. Can be set with a command
+// line argument.
+var civModeFilterWidths = make(map[string][]int)
+
+// Standard time and frequency stations for propagation checks.
+type timeStation struct {
+	name string
+	freq uint64
+}
+
+// Calling and net frequencies, the frequency display is highlighted when the main VFO is on one of them.
+type watchedFreq struct {
+	name string
+	freq uint64
+}
+
+// The TX delay (time between PTT and RF, for sequencing amplifiers and transverters) is set separately
+// for these band groups, using the IC-705's 0x1a 0x05 menu items.
+type civTXDelayGroup struct {
+	name     string
+	freqFrom uint64
+	item     []byte
+}
+
+var civTXDelayGroups = []civTXDelayGroup{
+	{name: "HF", freqFrom: 0, item: []byte{0x01, 0x67}},
+	{name: "50M", freqFrom: 50000000, item: []byte{0x01, 0x68}},
+	{name: "144M", freqFrom: 144000000, item: []byte{0x01, 0x69}},
+	{name: "430M", freqFrom: 420000000, item: []byte{0x01, 0x70}},
+}
+
+var civTXDelays = []string{"OFF", "10ms", "15ms", "20ms", "25ms", "30ms"}
+
 type civFilter struct {
 	name string
 	code byte
@@ -52,9 +132,11 @@ var civFilters = []civFilter{
 //	definitely needed since it appears this tool will push the PTT at any freq it's tuned to
 //	 question is how does the radio react
 type civBand struct {
-	freqFrom uint
-	freqTo   uint
-	freq     uint
+	name      string
+	freqFrom  uint64
+	freqTo    uint64
+	freq      uint64
+	entryFreq uint64 // if set, then band changes always land on this frequency
 }
 
 // NOTE: check these against US band assignments
@@ -81,18 +163,18 @@ var civBands = []civBand{
 		{freqFrom: 0, freqTo: 0},                 // GENE - general is ok for rx, but tx has statuatory limitations
 	*/
 
-	{freqFrom: 1800000, freqTo: 2000000},     // 1.9 - 160m
-	{freqFrom: 3500000, freqTo: 4000000},     // 3.5 - 75/80m
-	{freqFrom: 7000000, freqTo: 7300000},     // 7 - 40m
-	{freqFrom: 10100000, freqTo: 10150000},   // 10 - 30m data modes only in US
-	{freqFrom: 14000000, freqTo: 14350000},   // 14 - 20m
-	{freqFrom: 18068000, freqTo: 18168000},   // 18 -17m
-	{freqFrom: 21000000, freqTo: 21450000},   // 21 - 15m
-	{freqFrom: 24890000, freqTo: 24990000},   // 24 - 12m
-	{freqFrom: 28000000, freqTo: 29700000},   // 28 - 10m
-	{freqFrom: 50000000, freqTo: 54000000},   // 50 - 6m
-	{freqFrom: 144000000, freqTo: 148000000}, // 144 - 2m
-	{freqFrom: 420000000, freqTo: 450000000}, // 430 - 70cm
+	{name: "160m", freqFrom: 1800000, freqTo: 2000000},     // 1.9 - 160m
+	{name: "80m", freqFrom: 3500000, freqTo: 4000000},      // 3.5 - 75/80m
+	{name: "40m", freqFrom: 7000000, freqTo: 7300000},      // 7 - 40m
+	{name: "30m", freqFrom: 10100000, freqTo: 10150000},    // 10 - 30m data modes only in US
+	{name: "20m", freqFrom: 14000000, freqTo: 14350000},    // 14 - 20m
+	{name: "17m", freqFrom: 18068000, freqTo: 18168000},    // 18 -17m
+	{name: "15m", freqFrom: 21000000, freqTo: 21450000},    // 21 - 15m
+	{name: "12m", freqFrom: 24890000, freqTo: 24990000},    // 24 - 12m
+	{name: "10m", freqFrom: 28000000, freqTo: 29700000},    // 28 - 10m
+	{name: "6m", freqFrom: 50000000, freqTo: 54000000},     // 50 - 6m
+	{name: "2m", freqFrom: 144000000, freqTo: 148000000},   // 144 - 2m
+	{name: "70cm", freqFrom: 420000000, freqTo: 450000000}, // 430 - 70cm
 	//{freqFrom: 0, freqTo: 0},                 // GENE // doesn't seem needed or useful
 	// NOTE: IC-705 doesn't support 33cm or higher, but it's twin the IC-905 does so we may think about that going forward
 }
@@ -111,6 +193,14 @@ type civCmd struct {
 	sentAt  time.Time
 	name    string
 	cmd     []byte
+	// Set when the command was resent, the latency of its answer is not measured as we don't know
+	// which send it belongs to.
+	retried bool
+	// The number of sends of a set command which are not answered with OK/NG yet.
+	unconfirmed int
+	// Set while the command is waiting in the send queue. It's not retried until it's sent, and sentAt is
+	// set when it's actually sent.
+	queued bool
 }
 
 type civControlStruct struct {
@@ -120,79 +210,216 @@ type civControlStruct struct {
 	resetSReadTimer    chan bool
 	newPendingCmdAdded chan bool
 
+	// If --civ-cmd-gap is set, then commands are sent through this queue by sendLoop().
+	sendQueue              chan *civCmd
+	sendLoopDeinitNeeded   chan bool
+	sendLoopDeinitFinished chan bool
+
 	state struct {
-		mutex       sync.Mutex
-		pendingCmds []*civCmd
-
-		getFreq           civCmd // NOTE: why was this removed in v1.3-devel version?
-		getPwr            civCmd
-		getS              civCmd // get S-meter reading
-		getOVF            civCmd
-		getSWR            civCmd
-		getTransmitStatus civCmd
-		getPreamp         civCmd
-		getAGC            civCmd
-		getTuneStatus     civCmd
-		getVd             civCmd // get Vd meter reading
-		getTuningStep     civCmd
-		getRFGain         civCmd
-		getSQL            civCmd
-		getNR             civCmd
-		getNREnabled      civCmd
-		getSplit          civCmd
-		getMainVFOFreq    civCmd
-		getSubVFOFreq     civCmd
-		getMainVFOMode    civCmd
-		getSubVFOMode     civCmd
-
-		lastSReceivedAt       time.Time
-		lastOVFReceivedAt     time.Time
-		lastSWRReceivedAt     time.Time
-		lastVFOFreqReceivedAt time.Time
-
-		setPwr         civCmd
-		setRFGain      civCmd
-		setSQL         civCmd
-		setNR          civCmd
-		setMainVFOFreq civCmd
-		setSubVFOFreq  civCmd
-		setMode        civCmd
-		setSubVFOMode  civCmd
-		setPTT         civCmd
-		setTune        civCmd
-		setDataMode    civCmd
-		setPreamp      civCmd
-		setAGC         civCmd
-		setNREnabled   civCmd
-		setTuningStep  civCmd
-		setVFO         civCmd
-		setSplit       civCmd
-
-		pttTimeoutTimer  *time.Timer
-		tuneTimeoutTimer *time.Timer
-
-		freq                uint
-		subFreq             uint
-		ptt                 bool
-		tune                bool
-		pwrLevel            int
-		rfGainLevel         int
-		sqlLevel            int
-		nrLevel             int
-		nrEnabled           bool
-		operatingModeIdx    int
-		dataMode            bool
-		filterIdx           int
-		subOperatingModeIdx int
-		subDataMode         bool
-		subFilterIdx        int
-		bandIdx             int
-		preamp              int
-		agc                 int
-		tsValue             byte
-		ts                  uint
-		vfoBActive          bool
-		splitMode           splitMode
+		mutex sync.Mutex
+		civControlState
+	}
+}
+
+// The state of the radio and of the sent commands, protected by the state mutex. It is a separate type,
+// so it can be reset without overwriting the mutex.
+type civControlState struct {
+	pendingCmds []*civCmd
+
+	getFreq           civCmd // NOTE: why was this removed in v1.3-devel version?
+	getPwr            civCmd
+	getS              civCmd // get S-meter reading
+	getOVF            civCmd
+	getSWR            civCmd
+	getALC            civCmd
+	getComp           civCmd
+	getTransmitStatus civCmd
+	getPreamp         civCmd
+	getAttenuator     civCmd
+	getAGC            civCmd
+	getTuneStatus     civCmd
+	getVd             civCmd // get Vd meter reading
+	getTuningStep     civCmd
+	getRFGain         civCmd
+	getSQL            civCmd
+	getNR             civCmd
+	getNREnabled      civCmd
+	getNB             civCmd
+	getNBEnabled      civCmd
+	getNBDepth        civCmd
+	getNBWidth        civCmd
+	getSplit          civCmd
+	getDuplexOffset   civCmd
+	getMainVFOFreq    civCmd
+	getSubVFOFreq     civCmd
+	getMainVFOMode    civCmd
+	getSubVFOMode     civCmd
+	getRITEnabled     civCmd
+	getXITEnabled     civCmd
+	getXIT            civCmd
+	getDualWatch      civCmd
+	getDialLock       civCmd
+	getTransceive     civCmd
+	getPowerSource    civCmd
+	getSquelchStatus  civCmd
+	getScopeOn        civCmd
+	getScopeHold      civCmd
+	getPBT1           civCmd
+	getPBT2           civCmd
+	getCWPitch        civCmd
+	getIFFilterWidth  civCmd
+
+	lastUserActionAt time.Time
+	idle             bool // meters are polled with idlePollInterval
+
+	lastSReceivedAt          time.Time
+	lastSquelchStatusAt      time.Time
+	lastOVFReceivedAt        time.Time
+	ovfPollingOff            bool
+	lastSWRReceivedAt        time.Time
+	lastALCReceivedAt        time.Time
+	lastCompReceivedAt       time.Time
+	lastVFOFreqPolledAt      time.Time
+	lastSubVFOFreqReceivedAt time.Time
+	lastPowerSourceAt        time.Time
+	lastPassbandPolledAt     time.Time
+	lastScopeStatePolledAt   time.Time
+
+	setPwr           civCmd
+	setRFGain        civCmd
+	setSQL           civCmd
+	setNR            civCmd
+	setMainVFOFreq   civCmd
+	setSubVFOFreq    civCmd
+	setMode          civCmd
+	setSubVFOMode    civCmd
+	setMainVFOMode   civCmd
+	setPTT           civCmd
+	setTune          civCmd
+	setTunerEnabled  civCmd
+	setDataMode      civCmd
+	setPreamp        civCmd
+	setAttenuator    civCmd
+	setAGC           civCmd
+	setNREnabled     civCmd
+	setNB            civCmd
+	setNBEnabled     civCmd
+	setNBDepth       civCmd
+	setNBWidth       civCmd
+	setTuningStep    civCmd
+	setVFO           civCmd
+	setVFOMode       civCmd
+	setMemoryMode    civCmd
+	setMemoryChannel civCmd
+	setSplit         civCmd
+	setRITEnabled    civCmd
+	setXITEnabled    civCmd
+	setXIT           civCmd
+	setVoiceTXMemory civCmd
+	setDualWatch     civCmd
+	setDialLock      civCmd
+	setTransceive    civCmd
+	setPBT1          civCmd
+	setScopeHold     civCmd
+	setPBT2          civCmd
+	speech           civCmd
+	sendCWMsg        civCmd
+	getKeyerMemory   civCmd
+	getMemoryName    civCmd
+	getTXDelay       civCmd
+	setTXDelay       civCmd
+	setKeyerMemory   civCmd
+
+	injectedCmd civCmd // sent by the CI-V command server
+
+	// CW messages longer than maxCWMsgLength are sent in parts, the next part is sent when the
+	// previous one is confirmed.
+	cwMsgQueue []string
+	// The keyer memory slot which should be sent when its contents are received.
+	keyerMemoryToSend byte
+
+	rawCmd           civCmd // sent by sendRawCIV()
+	rawCmdAnswerChan chan []byte
+
+	// Frames from addresses which are neither the transceiver's nor ours. These can come from other
+	// devices on the CI-V bus, or from corruption.
+	unknownDeviceFrames    int
+	unknownDeviceAddresses map[byte]bool
+
+	refusedCmds      map[string]bool // names of the commands which got an NG answer
+	unknownModeCodes map[byte]bool   // mode codes not in civOperatingModes which were already logged
+
+	// Averaged time between sending a command and decoding its answer.
+	cmdLatency time.Duration
+
+	pttTimeoutTimer  *time.Timer
+	tuneTimeoutTimer *time.Timer
+
+	freq                uint64
+	subFreq             uint64
+	ptt                 bool
+	tune                bool
+	tunerEnabled        bool
+	pwrLevelBeforeTune  int
+	restorePwrAfterTune bool
+	pwrLevel            int
+	rfGainLevel         int
+	sqlLevel            int
+	squelchClosed       bool
+	nrLevel             int
+	nrEnabled           bool
+	nbLevel             int
+	nbEnabled           bool
+	nbDepth             int // 0-9, displayed as 1-10
+	nbWidth             int // 0-255, displayed as 1-100
+	txInhibit           bool
+	txDelay             int // civTXDelays idx
+	txDelayGroupIdx     int
+	vd                  float64 // 0 if unknown
+	swr                 float64 // the last SWR reading on the current band, 0 if unknown
+	pbt1                int
+	pbt2                int
+	cwPitch             int    // Hz
+	ifFilterWidth       int    // Hz, 0 if unknown
+	filterWidthKey      string // civFilterWidths key of the current mode and filter
+	operatingModeIdx    int
+	gotMainMode         bool
+	dataMode            bool
+	filterIdx           int
+	subOperatingModeIdx int
+	modeCode            byte // the raw code, as the mode may be unknown
+	subModeCode         byte
+	subDataMode         bool
+	subFilterIdx        int
+	bandIdx             int
+	preamp              int
+	twoStagePreamp      bool
+	attenuator          int // in dB, 0 if off
+	agc                 int
+	tsValue             byte
+	ts                  uint
+	vfoBActive          bool
+	memoryMode          bool
+	memoryChannel       int // -1 if unknown
+	memoryName          string
+	dualWatch           bool
+	dialLock            bool
+	transceive          bool
+	splitMode           splitMode
+	duplexOffset        uint64 // in Hz
+	ritEnabled          bool
+	xitEnabled          bool
+	xitOffset           int
+	lastDTMF            string
+	sReadings           []sReading // of the last sPeakWindow
+	timeStationIdx      int        // the next time station to tune to
+	lastFreq            uint64
+	lastModeIdx         int
+	lastFilterIdx       int
+
+	scope struct {
+		on   bool
+		hold bool
 	}
 }
 
@@ -224,11 +451,14 @@ var CIV = CIVCmds{
 	// 0x07 // select VFO
 	"setVFO": CIVCmdSet{cmdSeq: []byte{0x07}}, // switch to operating in VFO mode
 	// 0x08 // switch to operating in memory mode
+	"setMemoryMode":    CIVCmdSet{cmdSeq: []byte{0x08}},
+	"setMemoryChannel": CIVCmdSet{cmdSeq: []byte{0x08}}, // followed by the channel (2 bytes BCD)
 	// 0x09
 	// 0x0a
 	// 0x0b
-	// 0x0c
-	// 0x0d
+	// 0x0c // read duplex offset frequency
+	"getDuplexOffset": CIVCmdSet{cmdSeq: []byte{0x0c}},
+	// 0x0d // send duplex offset frequency
 	// 0x0e // scanning related actions
 
 	// 0x0f // split & duplex
@@ -239,33 +469,54 @@ var CIV = CIVCmds{
 	// 0x10
 	"getTuningStep": CIVCmdSet{cmdSeq: []byte{0x10}},
 	"setTuningStep": CIVCmdSet{cmdSeq: []byte{0x10}},
-	// 0x11
+	// 0x11 // attenuator, 0x00 - off, 0x20 - 20dB
+	"getAttenuator": CIVCmdSet{cmdSeq: []byte{0x11}},
+	"setAttenuator": CIVCmdSet{cmdSeq: []byte{0x11}},
 	// 0x12 // no command documented
 	// 0x13 // enable various speech output ( for radio operation by visually impaired)
+	"speechAll":      CIVCmdSet{cmdSeq: []byte{0x13, 0x00}}, // S meter level, frequency and mode
+	"speechFreqAndS": CIVCmdSet{cmdSeq: []byte{0x13, 0x01}}, // frequency and S meter level
+	"speechMode":     CIVCmdSet{cmdSeq: []byte{0x13, 0x02}},
 	// 0x14 // gain, sqleuule, noise reduction,
-	"getRFGain": CIVCmdSet{cmdSeq: []byte{0x14, 0x02}},
-	"setRFGain": CIVCmdSet{cmdSeq: []byte{0x14, 0x02}},
-	"getSQL":    CIVCmdSet{cmdSeq: []byte{0x14, 0x03}},
-	"setSQL":    CIVCmdSet{cmdSeq: []byte{0x14, 0x03}},
-	"getNR":     CIVCmdSet{cmdSeq: []byte{0x14, 0x06}},
-	"setNR":     CIVCmdSet{cmdSeq: []byte{0x14, 0x06}},
-	"getPwr":    CIVCmdSet{cmdSeq: []byte{0x14, 0x0a}}, // RF Power
-	"setPwr":    CIVCmdSet{cmdSeq: []byte{0x14, 0x0a}},
+	"getRFGain":  CIVCmdSet{cmdSeq: []byte{0x14, 0x02}},
+	"setRFGain":  CIVCmdSet{cmdSeq: []byte{0x14, 0x02}},
+	"getSQL":     CIVCmdSet{cmdSeq: []byte{0x14, 0x03}},
+	"setSQL":     CIVCmdSet{cmdSeq: []byte{0x14, 0x03}},
+	"getNR":      CIVCmdSet{cmdSeq: []byte{0x14, 0x06}},
+	"setNR":      CIVCmdSet{cmdSeq: []byte{0x14, 0x06}},
+	"getPBT1":    CIVCmdSet{cmdSeq: []byte{0x14, 0x07}}, // twin PBT inside position, 0128 is center
+	"setPBT1":    CIVCmdSet{cmdSeq: []byte{0x14, 0x07}},
+	"getPBT2":    CIVCmdSet{cmdSeq: []byte{0x14, 0x08}}, // twin PBT outside position, 0128 is center
+	"setPBT2":    CIVCmdSet{cmdSeq: []byte{0x14, 0x08}},
+	"getNB":      CIVCmdSet{cmdSeq: []byte{0x14, 0x12}}, // noise blanker level
+	"setNB":      CIVCmdSet{cmdSeq: []byte{0x14, 0x12}},
+	"getCWPitch": CIVCmdSet{cmdSeq: []byte{0x14, 0x09}},
+	"getPwr":     CIVCmdSet{cmdSeq: []byte{0x14, 0x0a}}, // RF Power
+	"setPwr":     CIVCmdSet{cmdSeq: []byte{0x14, 0x0a}},
 	// 0x15
-	"getS":   CIVCmdSet{cmdSeq: []byte{0x15, 0x02}}, //read S-meter level
-	"getSWR": CIVCmdSet{cmdSeq: []byte{0x15, 0x12}},
-	"getVd":  CIVCmdSet{cmdSeq: []byte{0x15, 0x15}},
+	"getSquelchStatus": CIVCmdSet{cmdSeq: []byte{0x15, 0x01}}, // 0 - closed, 1 - open
+	"getS":             CIVCmdSet{cmdSeq: []byte{0x15, 0x02}}, //read S-meter level
+	"getSWR":           CIVCmdSet{cmdSeq: []byte{0x15, 0x12}},
+	"getALC":           CIVCmdSet{cmdSeq: []byte{0x15, 0x13}},
+	"getComp":          CIVCmdSet{cmdSeq: []byte{0x15, 0x14}}, // speech compressor meter
+	"getVd":            CIVCmdSet{cmdSeq: []byte{0x15, 0x15}},
 	// 0x16 // misc - preamp, NB, NR, filters, tone squelches, etc
 	"getPreamp":    CIVCmdSet{cmdSeq: []byte{0x16, 0x02}},
 	"setPreamp":    CIVCmdSet{cmdSeq: []byte{0x16, 0x02}},
 	"getAGC":       CIVCmdSet{cmdSeq: []byte{0x16, 0x12}},
 	"setAGC":       CIVCmdSet{cmdSeq: []byte{0x16, 0x12}},
+	"getNBEnabled": CIVCmdSet{cmdSeq: []byte{0x16, 0x22}},
+	"setNBEnabled": CIVCmdSet{cmdSeq: []byte{0x16, 0x22}},
 	"getNREnabled": CIVCmdSet{cmdSeq: []byte{0x16, 0x40}},
 	"setNREnabled": CIVCmdSet{cmdSeq: []byte{0x16, 0x40}},
+	"getDialLock":  CIVCmdSet{cmdSeq: []byte{0x16, 0x50}},
+	"setDialLock":  CIVCmdSet{cmdSeq: []byte{0x16, 0x50}},
+	"getDualWatch": CIVCmdSet{cmdSeq: []byte{0x16, 0x59}},
+	"setDualWatch": CIVCmdSet{cmdSeq: []byte{0x16, 0x59}},
 	// 0x17 // send CW messages (up to 30 chars)
-	"sendCWMsg": CIVCmdSet{cmdSeq: []byte{0x17}},
-	// 0x18
-	// 0x19
+	"sendCWMsg": CIVCmdSet{cmdSeq: []byte{0x17}}, // 0xff stops sending
+	// 0x18 // power off/on, sent with sendRawCIV() as we wait for the answer
+	// 0x19 // read transceiver ID, sent with sendRawCIV() by selfTest()
 
 	// 0x1a // a lot of misc settings (VOX, GPS Pos, NTP, share pictures, pwr supply type)
 	// 0x1a 0x00 // memory contents
@@ -281,20 +532,41 @@ var CIV = CIVCmds{
 	// 0x1a 0x09 // OVF
 	// 0x1a 0x0a // share pictures
 	// 0x1a 0x0b // pwr supply
-	"getDataMode": CIVCmdSet{cmdSeq: []byte{0x1a, 0x06}},
-	"setDataMode": CIVCmdSet{cmdSeq: []byte{0x1a, 0x06}},
-	"getOVF":      CIVCmdSet{cmdSeq: []byte{0x1a, 0x09}},
+	"getMemoryName":    CIVCmdSet{cmdSeq: []byte{0x1a, 0x00}}, // followed by the group and the channel
+	"getKeyerMemory":   CIVCmdSet{cmdSeq: []byte{0x1a, 0x02}}, // followed by the slot (1-8)
+	"setKeyerMemory":   CIVCmdSet{cmdSeq: []byte{0x1a, 0x02}},
+	"getIFFilterWidth": CIVCmdSet{cmdSeq: []byte{0x1a, 0x03}},
+	"getDataMode":      CIVCmdSet{cmdSeq: []byte{0x1a, 0x06}},
+	"setDataMode":      CIVCmdSet{cmdSeq: []byte{0x1a, 0x06}},
+	"getOVF":           CIVCmdSet{cmdSeq: []byte{0x1a, 0x09}},
+	"getTransceive":    CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x31}}, // CI-V transceive setting
+	"getNBDepth":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x89}}, // 00-09 (1-10)
+	"setNBDepth":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x89}},
+	"getNBWidth":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x90}}, // 0000-0255 (1-100)
+	"setNBWidth":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x90}},
+	"getTXDelay":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05}}, // followed by the band group's menu item
+	"setTXDelay":       CIVCmdSet{cmdSeq: []byte{0x1a, 0x05}},
+	"setTransceive":    CIVCmdSet{cmdSeq: []byte{0x1a, 0x05, 0x01, 0x31}},
+	"getPowerSource":   CIVCmdSet{cmdSeq: []byte{0x1a, 0x0b}}, // 0 - battery pack, 1 - external
 	// 0x1b // repeater tone|tsql|dtcs|csql settings
 	// 0x1c // PTT, ant tuner, XFC  on|off
 	"getTransmitStatus": CIVCmdSet{cmdSeq: []byte{0x1c, 0x00}}, // is radio doing Rx or Tx
 	"setPTT":            CIVCmdSet{cmdSeq: []byte{0x1c, 0x00}}, // current code base does next 2 commands as "data"
 	"setTune":           CIVCmdSet{cmdSeq: []byte{0x1c, 0x01}}, // antenna tuner, NOT frequency tuning
+	"setTunerEnabled":   CIVCmdSet{cmdSeq: []byte{0x1c, 0x01}}, // antenna tuner in-line on|off
 	"getTuneStatus":     CIVCmdSet{cmdSeq: []byte{0x1c, 0x01}}, //  antenna tuner, NOT frequency tuning
 	// 0x1d // no command documented
 	// 0x1e // TX band edge settings
 	// 0x1f // DV (D-Star) my station & UR/R1/R2 settings
 	// 0x20 // various DV (D-Star) commands
 	// 0x21 // RIT (recieve increment tuning) settings
+	// NOTE: the IC-705 has a single offset register which is used for both RIT and XIT
+	"getXIT":        CIVCmdSet{cmdSeq: []byte{0x21, 0x00}},
+	"setXIT":        CIVCmdSet{cmdSeq: []byte{0x21, 0x00}},
+	"getRITEnabled": CIVCmdSet{cmdSeq: []byte{0x21, 0x01}},
+	"setRITEnabled": CIVCmdSet{cmdSeq: []byte{0x21, 0x01}},
+	"getXITEnabled": CIVCmdSet{cmdSeq: []byte{0x21, 0x02}}, // called delta TX in the radio's manual
+	"setXITEnabled": CIVCmdSet{cmdSeq: []byte{0x21, 0x02}},
 	// 0x22 // DV (D-Star) settings
 	// 0x23 // GPS position setting
 	// 0x24 // TX output power settings
@@ -309,7 +581,11 @@ var CIV = CIVCmds{
 	"getSubVFOMode":  CIVCmdSet{cmdSeq: []byte{0x26, 0x01}},
 	"setSubVFOMode":  CIVCmdSet{cmdSeq: []byte{0x26, 0x01}},
 	// 0x27 // scope settings
+	"getScopeOn":   CIVCmdSet{cmdSeq: []byte{0x27, 0x10}},       // 0 - off, 1 - on
+	"getScopeHold": CIVCmdSet{cmdSeq: []byte{0x27, 0x17, 0x00}}, // main scope, 0 - running, 1 - hold
+	"setScopeHold": CIVCmdSet{cmdSeq: []byte{0x27, 0x17, 0x00}},
 	// 0x28 // TX voice memory
+	"setVoiceTXMemory": CIVCmdSet{cmdSeq: []byte{0x28, 0x00}}, // 0 - cancel, 1-8 - play memory T1-T8
 	// nothing documented beyond 0x28
 }
 
@@ -349,7 +625,36 @@ func (s *civControlStruct) decode(d []byte) bool {
 	s.state.mutex.Lock()
 	defer s.state.mutex.Unlock()
 
+	// Frames from other devices on the bus (another radio for example) are only forwarded, decoding them
+	// would mix up the state of our radio.
+	if frm := d[3]; frm != civAddress && frm != controllerAddress {
+		s.reportUnknownDevice(frm)
+		return true
+	}
+
+	var answered bool
+	if s.state.injectedCmd.pending && s.isAnswerFor(&s.state.injectedCmd, d) {
+		civCmdSrv.reportAnswer(d)
+		s.confirmPendingCmd(&s.state.injectedCmd)
+		answered = true
+	}
+	if s.state.rawCmd.pending && s.isRawCmdAnswer(d) {
+		s.state.rawCmdAnswerChan <- append([]byte{}, d...)
+		s.confirmPendingCmd(&s.state.rawCmd)
+		answered = true
+	}
+
 	switch d[4] {
+	case OK:
+		if answered || d[3] != civAddress {
+			return true
+		}
+		return s.decodeOK()
+	case NG:
+		if answered || d[3] != civAddress {
+			return true
+		}
+		return s.decodeNG()
 	case 0x00: // send frequency data via transceive (to active VFO?)
 		return s.decodeFreq(payload)
 	case 0x01: // send mode data via transceive
@@ -366,12 +671,22 @@ func (s *civControlStruct) decode(d []byte) bool {
 		return s.decodeMode(payload)
 	case 0x07:
 		return s.decodeVFO(payload)
+	case 0x08:
+		return s.decodeMemoryMode(payload)
+	case 0x0c:
+		return s.decodeDuplexOffset(payload)
 	case 0x0f:
 		return s.decodeSplit(payload)
 	case 0x10:
 		return s.decodeTuningStep(payload)
+	case 0x11:
+		return s.decodeAttenuator(payload)
 	case 0x1a:
 		return s.decodeDataModeAndOVF(payload)
+	case 0x13:
+		return s.decodeSpeech(payload)
+	case 0x17:
+		return s.decodeCWMsg(payload)
 	case 0x14:
 		return s.decodePowerRFGainSQLNRPwr(payload)
 	case 0x1c:
@@ -380,14 +695,81 @@ func (s *civControlStruct) decode(d []byte) bool {
 		return s.decodeVdSWRS(payload)
 	case 0x16:
 		return s.decodePreampAGCNREnabled(payload)
+	case 0x21:
+		return s.decodeRITXIT(payload)
 	case 0x25:
 		return s.decodeVFOFreq(payload)
 	case 0x26:
 		return s.decodeVFOMode(payload)
+	case 0x28:
+		return s.decodeVoiceTXMemory(payload)
+	case 0x27:
+		return s.decodeScope(payload)
 	}
 	return true
 }
 
+// Set commands (everything which is not a query) are answered only with OK by the radio.
+func isSetCmd(cmd *civCmd) bool {
+	return !strings.HasPrefix(cmd.name, "get")
+}
+
+// returns the earliest sent pending command (only set commands if setOnly is true), nil if there's none
+func (s *civControlStruct) getEarliestPendingCmd(setOnly bool) *civCmd {
+	var cmd *civCmd
+	for _, c := range s.state.pendingCmds {
+		if (!setOnly || isSetCmd(c)) && (cmd == nil || c.sentAt.Before(cmd.sentAt)) {
+			cmd = c
+		}
+	}
+	return cmd
+}
+
+// The radio answers OK (without the cmd) if it accepted a set command. The radio answers in order, so
+// the answer belongs to the earliest sent pending set command, which is confirmed now. If the command
+// was sent again meanwhile, then it stays pending until all of its sends are confirmed. If there's no
+// pending set command, then the answer is forwarded.
+func (s *civControlStruct) decodeOK() bool {
+	cmd := s.getEarliestPendingCmd(true)
+	if cmd == nil {
+		return true
+	}
+	log.Debug("radio confirmed cmd ", cmd.name)
+	if cmd.unconfirmed > 1 {
+		cmd.unconfirmed--
+		return false
+	}
+	s.confirmPendingCmd(cmd)
+	return false
+}
+
+// The radio answers NG (without the cmd) if it refused a command, for example because it's not available
+// in the current mode. The radio answers in order, and set commands are only removed by OK/NG answers
+// (not by their echo), so the answer belongs to the earliest sent pending command, which is removed, as
+// retrying it would be refused again. Polled commands may be refused periodically, so an error is
+// logged only the first time a command is refused.
+func (s *civControlStruct) decodeNG() bool {
+	cmd := s.getEarliestPendingCmd(false)
+	if cmd == nil {
+		return true
+	}
+	if s.state.refusedCmds == nil {
+		s.state.refusedCmds = make(map[string]bool)
+	}
+	if s.state.refusedCmds[cmd.name] {
+		log.Debug("radio refused cmd ", cmd.name)
+	} else {
+		s.state.refusedCmds[cmd.name] = true
+		log.Error("radio refused cmd ", cmd.name)
+	}
+	if cmd.unconfirmed > 1 {
+		cmd.unconfirmed--
+		return false
+	}
+	s.dropPendingCmd(cmd)
+	return false
+}
+
 // NOTE: this was commented out... why? is it bcaus it doesn't know which VFO or always checks VFO A even if B selected?
 func (s *civControlStruct) decodeFreq(d []byte) bool {
 	if len(d) < 2 {
@@ -396,26 +778,177 @@ func (s *civControlStruct) decodeFreq(d []byte) bool {
 	s.state.freq = s.decodeFreqData(d)
 	statusLog.reportFrequency(s.state.freq)
 
+	s.updateBand()
+
+	if s.state.getFreq.pending {
+		s.removePendingCmd(&s.state.getFreq)
+		return false
+	}
+	if s.state.setMainVFOFreq.pending {
+		s.removePendingCmd(&s.state.setMainVFOFreq)
+		return false
+	}
+	return true
+}
+
+// Sets the band idx and reports the band name based on the main VFO frequency.
+func (s *civControlStruct) updateBand() {
+	prevBandIdx := s.state.bandIdx
 	s.state.bandIdx = len(civBands) - 1 // set the band idx to the last in range for a default (this was the general range) untile band is determined
+	var bandName string
 	for i := range civBands {
 		if s.state.freq >= civBands[i].freqFrom && s.state.freq <= civBands[i].freqTo {
 			s.state.bandIdx = i
 			civBands[s.state.bandIdx].freq = s.state.freq
+			bandName = civBands[i].name
 			break
 		}
 	}
+	statusLog.reportBand(bandName)
+	s.checkBandEdge()
+	s.checkWatchedFreq()
 
-	if s.state.getFreq.pending {
-		s.removePendingCmd(&s.state.getFreq)
+	if groupIdx := s.getTXDelayGroupIdx(); groupIdx != s.state.txDelayGroupIdx {
+		s.state.txDelayGroupIdx = groupIdx
+		_ = s.getTXDelay()
+	}
+
+	// The SWR is probably different on the new band, so the last reading is not used for TX checks.
+	if s.state.bandIdx != prevBandIdx {
+		s.state.swr = 0
+	}
+
+	if twoStage := s.isTwoStagePreamp(); twoStage != s.state.twoStagePreamp {
+		s.state.twoStagePreamp = twoStage
+		statusLog.reportPreamp(s.state.preamp, twoStage)
+	}
+}
+
+// In HF and 50MHz there is PAMP1 & PAMP2, in VHF/UHF the preamp can be only turned on or off.
+func (s *civControlStruct) isTwoStagePreamp() bool {
+	return s.state.freq <= twoStagePreampMaxFreq
+}
+
+// Reports the name of the watched frequency if the main VFO frequency is within the tolerance set by the
+// watch-freq-tolerance command line argument, empty otherwise.
+func (s *civControlStruct) checkWatchedFreq() {
+	var name string
+	for i := range watchedFreqs {
+		if s.state.freq+watchedFreqTolerance >= watchedFreqs[i].freq &&
+			s.state.freq <= watchedFreqs[i].freq+watchedFreqTolerance {
+			name = watchedFreqs[i].name
+			break
+		}
+	}
+	statusLog.reportWatchedFreq(name)
+}
+
+func (s *civControlStruct) decodeNBDepth(d []byte) bool {
+	if len(d) < 1 {
+		return !s.state.getNBDepth.pending && !s.state.setNBDepth.pending
+	}
+	s.state.nbDepth = int(d[0])
+	statusLog.reportNBDepthWidth(s.state.nbDepth+1, s.getNBWidthDisplayValue())
+	if s.state.getNBDepth.pending {
+		s.removePendingCmd(&s.state.getNBDepth)
 		return false
 	}
-	if s.state.setMainVFOFreq.pending {
-		s.removePendingCmd(&s.state.setMainVFOFreq)
+	if s.state.setNBDepth.pending {
+		s.removePendingCmd(&s.state.setNBDepth)
+		return false
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeNBWidth(d []byte) bool {
+	if len(d) < 2 {
+		return !s.state.getNBWidth.pending && !s.state.setNBWidth.pending
+	}
+	s.state.nbWidth = BCDToDec(d)
+	statusLog.reportNBDepthWidth(s.state.nbDepth+1, s.getNBWidthDisplayValue())
+	if s.state.getNBWidth.pending {
+		s.removePendingCmd(&s.state.getNBWidth)
+		return false
+	}
+	if s.state.setNBWidth.pending {
+		s.removePendingCmd(&s.state.setNBWidth)
+		return false
+	}
+	return true
+}
+
+// the radio displays the 0-255 NB width level as 1-100
+func (s *civControlStruct) getNBWidthDisplayValue() int {
+	return 1 + (s.state.nbWidth*99+127)/255
+}
+
+// returns the civTXDelayGroups idx for the main VFO frequency
+func (s *civControlStruct) getTXDelayGroupIdx() int {
+	idx := 0
+	for i := range civTXDelayGroups {
+		if s.state.freq >= civTXDelayGroups[i].freqFrom {
+			idx = i
+		}
+	}
+	return idx
+}
+
+func (s *civControlStruct) isTXDelayItem(item []byte) bool {
+	for i := range civTXDelayGroups {
+		if bytes.Equal(civTXDelayGroups[i].item, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *civControlStruct) decodeTXDelay(item, data []byte) bool {
+	if len(data) < 1 {
+		return !s.state.getTXDelay.pending && !s.state.setTXDelay.pending
+	}
+	// Only the current band group's setting is displayed.
+	if bytes.Equal(item, civTXDelayGroups[s.state.txDelayGroupIdx].item) && int(data[0]) < len(civTXDelays) {
+		s.state.txDelay = int(data[0])
+		statusLog.reportTXDelay(civTXDelays[s.state.txDelay])
+	}
+	if s.state.getTXDelay.pending {
+		s.removePendingCmd(&s.state.getTXDelay)
 		return false
 	}
 	return true
 }
 
+func (s *civControlStruct) isInBand(freq uint64) bool {
+	for i := range civBands {
+		if freq >= civBands[i].freqFrom && freq <= civBands[i].freqTo {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports a warning if the main VFO frequency is outside of the known bands, or it's closer to a band edge
+// than the margin set by the band-edge-margin command line argument.
+func (s *civControlStruct) checkBandEdge() {
+	if bandEdgeMargin == 0 {
+		return
+	}
+
+	warning := "OUT OF BAND"
+	for i := range civBands {
+		if s.state.freq < civBands[i].freqFrom || s.state.freq > civBands[i].freqTo {
+			continue
+		}
+		if s.state.freq-civBands[i].freqFrom < bandEdgeMargin || civBands[i].freqTo-s.state.freq < bandEdgeMargin {
+			warning = "BAND EDGE"
+		} else {
+			warning = ""
+		}
+		break
+	}
+	statusLog.reportBandEdge(warning)
+}
+
 func (s *civControlStruct) decodeFilterValueToFilterIdx(v byte) int {
 	for i := range civFilters {
 		if civFilters[i].code == v {
@@ -425,26 +958,89 @@ func (s *civControlStruct) decodeFilterValueToFilterIdx(v byte) int {
 	return 0
 }
 
+// The IF filter width is sent as a BCD index. In SSB and CW modes 00-09 are 50-500Hz in 50Hz steps,
+// 10-40 are 600-3600Hz in 100Hz steps.
+func (s *civControlStruct) decodeIFFilterWidth(v byte) int {
+	idx := int(v>>4)*10 + int(v&0x0f)
+	if idx < 10 {
+		return (idx + 1) * 50
+	}
+	return (idx - 4) * 100
+}
+
+// Encodes a width in Hz to the BCD index used in SSB and CW modes, see decodeIFFilterWidth().
+func (s *civControlStruct) encodeIFFilterWidth(width int) (byte, error) {
+	var idx int
+	switch {
+	case width >= 50 && width <= 500 && width%50 == 0:
+		idx = width/50 - 1
+	case width >= 600 && width <= 3600 && width%100 == 0:
+		idx = width/100 + 4
+	default:
+		return 0, fmt.Errorf("invalid filter width %d, it should be 50-500Hz in 50Hz or 600-3600Hz in 100Hz steps", width)
+	}
+	return byte(idx/10)<<4 | byte(idx%10), nil
+}
+
+// Calculates the effective passband from the IF filter width and the twin PBT positions, and reports
+// it to the status log. Each PBT knob shifts one of two filters in series by up to half of the filter
+// width, so the passband is where the two filters overlap. SSB filters are assumed to be centered on
+// 1500Hz, CW filters on the CW pitch.
+func (s *civControlStruct) updatePassband() {
+	if s.state.ifFilterWidth == 0 || s.state.operatingModeIdx < 0 ||
+		!s.isSSBOrCWMode(civOperatingModes[s.state.operatingModeIdx].code) {
+		statusLog.reportFilterWidth(0)
+		statusLog.reportPassband(0, 0)
+		return
+	}
+	statusLog.reportFilterWidth(s.state.ifFilterWidth)
+
+	center := 1500
+	if s.isCWMode(civOperatingModes[s.state.operatingModeIdx].code) {
+		if s.state.cwPitch == 0 {
+			statusLog.reportPassband(0, 0)
+			return
+		}
+		center = s.state.cwPitch
+	}
+	shift1 := (s.state.pbt1 - 128) * s.state.ifFilterWidth / 256
+	shift2 := (s.state.pbt2 - 128) * s.state.ifFilterWidth / 256
+	if shift2 > shift1 {
+		shift1, shift2 = shift2, shift1
+	}
+	low := center - s.state.ifFilterWidth/2 + shift1
+	high := center + s.state.ifFilterWidth/2 + shift2
+	if low < 0 {
+		low = 0
+	}
+	if high < low {
+		high = low
+	}
+	statusLog.reportPassband(low, high)
+}
+
 func (s *civControlStruct) decodeMode(d []byte) bool {
 	if len(d) < 1 {
 		return !s.state.setMode.pending
 	}
 
-	for i := range civOperatingModes {
-		if civOperatingModes[i].code == d[0] {
-			s.state.operatingModeIdx = i
-			break
-		}
+	prevOperatingModeIdx := s.state.operatingModeIdx
+	modeIdx := s.findOperatingModeIdx(d[0])
+	if modeIdx >= 0 || !keepModeOnUnknown {
+		s.state.operatingModeIdx = modeIdx
+		s.state.modeCode = d[0]
 	}
+	s.applyModeSettingsIfNeeded(prevOperatingModeIdx)
 
 	if len(d) > 1 {
 		s.state.filterIdx = s.decodeFilterValueToFilterIdx(d[1])
 	}
 	statusLog.reportMode(
-		civOperatingModes[s.state.operatingModeIdx].name,
+		s.getModeDisplayName(s.state.operatingModeIdx, s.state.modeCode),
 		s.state.dataMode,
 		civFilters[s.state.filterIdx].name,
 	)
+	s.updateFilterWidth()
 
 	if s.state.setMode.pending {
 		s.removePendingCmd(&s.state.setMode)
@@ -453,16 +1049,90 @@ func (s *civControlStruct) decodeMode(d []byte) bool {
 	return true
 }
 
+// Called when the mode or the filter may have changed. The cached width of the new filter is displayed
+// right away, and the actual width is read from the radio, as it can be changed in the radio's menu.
+func (s *civControlStruct) updateFilterWidth() {
+	if s.state.operatingModeIdx < 0 {
+		return
+	}
+	key := civOperatingModes[s.state.operatingModeIdx].name + "/" + civFilters[s.state.filterIdx].name
+	if key == s.state.filterWidthKey {
+		return
+	}
+	s.state.filterWidthKey = key
+	s.state.ifFilterWidth = civFilterWidths[key]
+	s.updatePassband()
+	if s.isSSBOrCWMode(civOperatingModes[s.state.operatingModeIdx].code) && !s.state.getIFFilterWidth.pending {
+		_ = s.getIFFilterWidth()
+	}
+}
+
+// Applies the settings of the main VFO's operating mode if the mode has been changed.
+func (s *civControlStruct) applyModeSettingsIfNeeded(prevOperatingModeIdx int) {
+	// the sensitivity is derived from another control in the new mode
+	defer s.reportSensitivity()
+
+	if !s.state.gotMainMode {
+		// This is the first mode we got, so it's not a mode change.
+		s.state.gotMainMode = true
+		return
+	}
+	if s.state.operatingModeIdx < 0 || s.state.operatingModeIdx == prevOperatingModeIdx {
+		return
+	}
+	s.applyModeTuningStep()
+	s.applyModeSQL(prevOperatingModeIdx)
+}
+
+// Sets the default tuning step of the main VFO's operating mode.
+func (s *civControlStruct) applyModeTuningStep() {
+	b, found := civModeTuningSteps[civOperatingModes[s.state.operatingModeIdx].name]
+	if !found || b == s.state.tsValue {
+		return
+	}
+	_ = s.setTuningStep(b)
+}
+
+// Stores the squelch level of the previous operating mode, and restores the last used squelch level
+// of the new one, so for example the FM squelch level won't mute SSB.
+func (s *civControlStruct) applyModeSQL(prevOperatingModeIdx int) {
+	if prevOperatingModeIdx >= 0 {
+		civModeSQLLevels[civOperatingModes[prevOperatingModeIdx].name] = s.state.sqlLevel
+	}
+	level, found := civModeSQLLevels[civOperatingModes[s.state.operatingModeIdx].name]
+	if !found || level == s.state.sqlLevel {
+		return
+	}
+	_ = s.setSQL(level)
+}
+
+// The radio can't be queried whether it's in VFO or memory mode, so the mode is tracked by the VFO and
+// memory mode select commands seen on the bus.
+func (s *civControlStruct) reportMemoryMode(memoryMode bool) {
+	s.state.memoryMode = memoryMode
+	statusLog.reportMemoryMode(memoryMode)
+}
+
 func (s *civControlStruct) decodeVFO(d []byte) bool {
 	if len(d) < 1 {
+		s.reportMemoryMode(false)
+		if s.state.setVFOMode.pending {
+			_ = s.getBothVFOFreq()
+			s.removePendingCmd(&s.state.setVFOMode)
+			return false
+		}
 		return !s.state.setVFO.pending
 	}
 
+	if d[0] <= 1 {
+		s.reportMemoryMode(false)
+	}
 	if d[0] == 1 {
 		s.state.vfoBActive = true
 	} else {
 		s.state.vfoBActive = false
 	}
+	statusLog.reportVFO(s.state.vfoBActive)
 
 	if s.state.setVFO.pending {
 		// The radio does not send frequencies automatically.
@@ -473,6 +1143,127 @@ func (s *civControlStruct) decodeVFO(d []byte) bool {
 	return true
 }
 
+func (s *civControlStruct) decodeSpeech(d []byte) bool {
+	if len(d) < 1 {
+		return !s.state.speech.pending
+	}
+
+	if s.state.speech.pending {
+		s.removePendingCmd(&s.state.speech)
+		return false
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeVoiceTXMemory(d []byte) bool {
+	if len(d) < 2 {
+		return !s.state.setVoiceTXMemory.pending
+	}
+
+	if s.state.setVoiceTXMemory.pending {
+		s.removePendingCmd(&s.state.setVoiceTXMemory)
+		return false
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeScope(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
+	switch d[0] {
+	case 0x10:
+		if len(d) < 2 {
+			return !s.state.getScopeOn.pending
+		}
+		s.state.scope.on = d[1] != 0
+		statusLog.reportScope(s.state.scope.on, s.state.scope.hold)
+		if s.state.getScopeOn.pending {
+			s.removePendingCmd(&s.state.getScopeOn)
+			return false
+		}
+	case 0x17:
+		if len(d) < 3 {
+			return !s.state.getScopeHold.pending && !s.state.setScopeHold.pending
+		}
+		if d[1] != 0x00 { // only the main scope is tracked
+			return true
+		}
+		s.state.scope.hold = d[2] != 0
+		statusLog.reportScope(s.state.scope.on, s.state.scope.hold)
+		if s.state.getScopeHold.pending {
+			s.removePendingCmd(&s.state.getScopeHold)
+			return false
+		}
+		if s.state.setScopeHold.pending {
+			s.removePendingCmd(&s.state.setScopeHold)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeCWMsg(d []byte) bool {
+	if !s.state.sendCWMsg.pending {
+		return true
+	}
+	s.removePendingCmd(&s.state.sendCWMsg)
+
+	if len(s.state.cwMsgQueue) > 0 {
+		part := s.state.cwMsgQueue[0]
+		s.state.cwMsgQueue = s.state.cwMsgQueue[1:]
+		if err := s.sendCWMsgPart([]byte(part)); err != nil {
+			log.Error("can't send cw message: ", err)
+		}
+	}
+	return false
+}
+
+func (s *civControlStruct) decodeMemoryMode(d []byte) bool {
+	// memory mode is also selected if a memory channel is given
+	s.reportMemoryMode(true)
+	if len(d) >= 2 {
+		if ch := s.decodeLevelData(d[:2]); ch != s.state.memoryChannel {
+			s.state.memoryChannel = ch
+			s.state.memoryName = ""
+			statusLog.reportMemoryChannel(ch, "")
+			_ = s.getMemoryName(ch)
+		}
+	}
+	if s.state.setMemoryMode.pending {
+		_ = s.getBothVFOFreq()
+		s.removePendingCmd(&s.state.setMemoryMode)
+		return false
+	}
+	if s.state.setMemoryChannel.pending {
+		_ = s.getBothVFOFreq()
+		s.removePendingCmd(&s.state.setMemoryChannel)
+		return false
+	}
+	return true
+}
+
+// The memory contents answer is the group, the channel (2 bytes BCD) and the channel data, which ends
+// with the 16 character name. A blank channel only has 0xff as data.
+func (s *civControlStruct) decodeMemoryName(d []byte) bool {
+	if len(d) < 4 {
+		return !s.state.getMemoryName.pending
+	}
+	if s.decodeLevelData(d[1:3]) == s.state.memoryChannel {
+		var name string
+		if d[3] != 0xff && len(d) >= 3+memoryNameLength {
+			name = strings.TrimSpace(string(d[len(d)-memoryNameLength:]))
+		}
+		s.state.memoryName = name
+		statusLog.reportMemoryChannel(s.state.memoryChannel, name)
+	}
+	if s.state.getMemoryName.pending {
+		s.removePendingCmd(&s.state.getMemoryName)
+		return false
+	}
+	return true
+}
+
 func (s *civControlStruct) decodeSplit(d []byte) bool {
 	if len(d) < 1 {
 		return !s.state.getSplit.pending && !s.state.setSplit.pending
@@ -495,6 +1286,11 @@ func (s *civControlStruct) decodeSplit(d []byte) bool {
 	}
 	statusLog.reportSplit(s.state.splitMode, str)
 
+	// The repeater offset is needed for displaying the TX frequency, it's not sent by the radio.
+	if s.state.splitMode == splitModeDUPMinus || s.state.splitMode == splitModeDUPPlus {
+		_ = s.getDuplexOffset()
+	}
+
 	if s.state.getSplit.pending {
 		s.removePendingCmd(&s.state.getSplit)
 		return false
@@ -506,6 +1302,21 @@ func (s *civControlStruct) decodeSplit(d []byte) bool {
 	return true
 }
 
+// The duplex offset is 3 bytes of BCD in 100Hz units.
+func (s *civControlStruct) decodeDuplexOffset(d []byte) bool {
+	if len(d) < 3 {
+		return !s.state.getDuplexOffset.pending
+	}
+	s.state.duplexOffset = s.decodeFreqData(d[:3]) * 100
+	statusLog.reportDuplexOffset(s.state.duplexOffset)
+
+	if s.state.getDuplexOffset.pending {
+		s.removePendingCmd(&s.state.getDuplexOffset)
+		return false
+	}
+	return true
+}
+
 func (s *civControlStruct) decodeTuningStep(d []byte) bool {
 	if len(d) < 1 {
 		return !s.state.getTuningStep.pending && !s.state.setTuningStep.pending
@@ -513,35 +1324,10 @@ func (s *civControlStruct) decodeTuningStep(d []byte) bool {
 
 	s.state.tsValue = d[0]
 
-	switch s.state.tsValue {
-	default:
+	if int(s.state.tsValue) < len(civTuningSteps) {
+		s.state.ts = civTuningSteps[s.state.tsValue]
+	} else {
 		s.state.ts = 1
-	case 1:
-		s.state.ts = 100
-	case 2:
-		s.state.ts = 500
-	case 3:
-		s.state.ts = 1000
-	case 4:
-		s.state.ts = 5000
-	case 5:
-		s.state.ts = 6250
-	case 6:
-		s.state.ts = 8330
-	case 7:
-		s.state.ts = 9000
-	case 8:
-		s.state.ts = 10000
-	case 9:
-		s.state.ts = 12500
-	case 10:
-		s.state.ts = 20000
-	case 11:
-		s.state.ts = 25000
-	case 12:
-		s.state.ts = 50000
-	case 13:
-		s.state.ts = 100000
 	}
 	statusLog.reportTuningStep(s.state.ts)
 
@@ -556,8 +1342,86 @@ func (s *civControlStruct) decodeTuningStep(d []byte) bool {
 	return true
 }
 
-func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
+// The attenuator can also be switched on the radio's front panel, and the radio sends the change if
+// transceive is on.
+func (s *civControlStruct) decodeAttenuator(d []byte) bool {
+	if len(d) < 1 {
+		return !s.state.getAttenuator.pending && !s.state.setAttenuator.pending
+	}
+
+	s.state.attenuator = int(d[0]>>4)*10 + int(d[0]&0x0f)
+	statusLog.reportAttenuator(s.state.attenuator)
+
+	if s.state.getAttenuator.pending {
+		s.removePendingCmd(&s.state.getAttenuator)
+		return false
+	}
+	if s.state.setAttenuator.pending {
+		s.removePendingCmd(&s.state.setAttenuator)
+		return false
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
 	switch d[0] {
+	case 0x00:
+		return s.decodeMemoryName(d[1:])
+	case 0x05:
+		if len(d) >= 3 && s.isTXDelayItem(d[1:3]) {
+			return s.decodeTXDelay(d[1:3], d[3:])
+		}
+		if len(d) >= 3 && bytes.Equal(d[1:3], CIV["getNBDepth"].cmdSeq[2:]) {
+			return s.decodeNBDepth(d[3:])
+		}
+		if len(d) >= 3 && bytes.Equal(d[1:3], CIV["getNBWidth"].cmdSeq[2:]) {
+			return s.decodeNBWidth(d[3:])
+		}
+		if len(d) < 3 || d[1] != 0x01 || d[2] != 0x31 {
+			return true
+		}
+		if len(d) < 4 {
+			return !s.state.getTransceive.pending && !s.state.setTransceive.pending
+		}
+		if transceive := d[3] != 0; transceive != s.state.transceive {
+			s.state.transceive = transceive
+			if transceive {
+				log.Print("transceive is on, frequency polling is reduced")
+			} else {
+				log.Print("transceive is off")
+			}
+		}
+		if s.state.getTransceive.pending {
+			s.removePendingCmd(&s.state.getTransceive)
+			return false
+		}
+		if s.state.setTransceive.pending {
+			s.removePendingCmd(&s.state.setTransceive)
+			return false
+		}
+	case 0x02:
+		// our own echoed get command only contains the slot
+		if len(d) < 3 {
+			return !s.state.getKeyerMemory.pending && !s.state.setKeyerMemory.pending
+		}
+		if s.state.setKeyerMemory.pending {
+			s.removePendingCmd(&s.state.setKeyerMemory)
+			return false
+		}
+		if s.state.getKeyerMemory.pending {
+			s.removePendingCmd(&s.state.getKeyerMemory)
+			if d[1] == s.state.keyerMemoryToSend {
+				s.state.keyerMemoryToSend = 0
+				log.Print("sending keyer memory M", d[1], ": ", string(d[2:]))
+				if err := s.sendCWMsg(string(d[2:])); err != nil {
+					log.Error("can't send keyer memory: ", err)
+				}
+			}
+			return false
+		}
 	case 0x06:
 		if len(d) < 3 {
 			return !s.state.setDataMode.pending
@@ -569,8 +1433,9 @@ func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
 			s.state.dataMode = false
 		}
 
-		statusLog.reportMode(civOperatingModes[s.state.operatingModeIdx].name, s.state.dataMode,
+		statusLog.reportMode(s.getModeDisplayName(s.state.operatingModeIdx, s.state.modeCode), s.state.dataMode,
 			civFilters[s.state.filterIdx].name)
+		s.updateFilterWidth()
 
 		if s.state.setDataMode.pending {
 			s.removePendingCmd(&s.state.setDataMode)
@@ -590,6 +1455,28 @@ func (s *civControlStruct) decodeDataModeAndOVF(d []byte) bool {
 			s.removePendingCmd(&s.state.getOVF)
 			return false
 		}
+	case 0x03:
+		if len(d) < 2 {
+			return !s.state.getIFFilterWidth.pending
+		}
+		s.state.ifFilterWidth = s.decodeIFFilterWidth(d[1])
+		if s.state.filterWidthKey != "" {
+			civFilterWidths[s.state.filterWidthKey] = s.state.ifFilterWidth
+		}
+		s.updatePassband()
+		if s.state.getIFFilterWidth.pending {
+			s.removePendingCmd(&s.state.getIFFilterWidth)
+			return false
+		}
+	case 0x0b:
+		if len(d) < 2 {
+			return !s.state.getPowerSource.pending
+		}
+		statusLog.reportPowerSource(d[1] == 0)
+		if s.state.getPowerSource.pending {
+			s.removePendingCmd(&s.state.getPowerSource)
+			return false
+		}
 	}
 	return true
 }
@@ -600,6 +1487,9 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 	// code would be easier to read if we check size and do value extraction first
 	//   then take actions on appropriate entities in each case
 
+	if len(d) < 1 {
+		return true
+	}
 	subcmd := d[0]
 	data := d[1:]
 	switch subcmd {
@@ -609,6 +1499,7 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 		}
 		s.state.rfGainLevel = BCDToDec(data)
 		statusLog.reportRFGain(s.state.rfGainLevel)
+		s.reportSensitivity()
 		if s.state.getRFGain.pending {
 			s.removePendingCmd(&s.state.getRFGain)
 			return false
@@ -623,11 +1514,13 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 		}
 		s.state.sqlLevel = BCDToDec(data)
 		statusLog.reportSQL(s.state.sqlLevel)
+		s.reportSensitivity()
 		if s.state.getSQL.pending {
 			s.removePendingCmd(&s.state.getSQL)
 			return false
 		}
 		if s.state.setSQL.pending {
+			s.removePendingCmd(&s.state.setSQL)
 			return false
 		}
 	case 0x06: // Noise Reduction level subcmd
@@ -644,6 +1537,20 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 			s.removePendingCmd(&s.state.setNR)
 			return false
 		}
+	case 0x12: // Noise Blanker level
+		if len(data) < 2 {
+			return !s.state.getNB.pending && !s.state.setNB.pending
+		}
+		s.state.nbLevel = BCDToDec(data)
+		statusLog.reportNB(s.state.nbLevel)
+		if s.state.getNB.pending {
+			s.removePendingCmd(&s.state.getNB)
+			return false
+		}
+		if s.state.setNB.pending {
+			s.removePendingCmd(&s.state.setNB)
+			return false
+		}
 	case 0x0a: //  RF Power Level subcmd
 		if len(data) < 2 {
 			return !s.state.getPwr.pending && !s.state.setPwr.pending
@@ -658,17 +1565,51 @@ func (s *civControlStruct) decodePowerRFGainSQLNRPwr(d []byte) bool {
 			s.removePendingCmd(&s.state.setPwr)
 			return false
 		}
-	// hooks for future functionality extension
-	case 0x01: // AF level (aka volume) subcmd
 	case 0x07: // PassBandTuning1 position
+		if len(data) < 2 {
+			return !s.state.getPBT1.pending && !s.state.setPBT1.pending
+		}
+		s.state.pbt1 = s.decodeLevelData(data)
+		s.updatePassband()
+		if s.state.getPBT1.pending {
+			s.removePendingCmd(&s.state.getPBT1)
+			return false
+		}
+		if s.state.setPBT1.pending {
+			s.removePendingCmd(&s.state.setPBT1)
+			return false
+		}
 	case 0x08: // PassBandTuning2 position
-	case 0x09: // CW pitch, 0000 = 300Hz, 0255 = 900Hz  each step is 5Hz
+		if len(data) < 2 {
+			return !s.state.getPBT2.pending && !s.state.setPBT2.pending
+		}
+		s.state.pbt2 = s.decodeLevelData(data)
+		s.updatePassband()
+		if s.state.getPBT2.pending {
+			s.removePendingCmd(&s.state.getPBT2)
+			return false
+		}
+		if s.state.setPBT2.pending {
+			s.removePendingCmd(&s.state.setPBT2)
+			return false
+		}
+	case 0x09: // CW pitch, 0000 = 300Hz, 0255 = 900Hz
+		if len(data) < 2 {
+			return !s.state.getCWPitch.pending
+		}
+		s.state.cwPitch = 300 + s.decodeLevelData(data)*600/255
+		s.updatePassband()
+		if s.state.getCWPitch.pending {
+			s.removePendingCmd(&s.state.getCWPitch)
+			return false
+		}
+	// hooks for future functionality extension
+	case 0x01: // AF level (aka volume) subcmd
 	case 0x0b: // mic gain
 	case 0x0c: // keying speed, 0000 = 6wpm, 0255 = 48wpm
 	case 0x0d: // notch filter setting, 0000 = max widdershins rotation, 0255 = max clockwise rotation
 	case 0x0e: // COMP level
 	case 0x0f: // break-in delay, 0000 = 2.0 d, 0255 = 13.0d
-	case 0x12: // Noise Blanker level
 	case 0x15: // Monitor audio level
 	case 0x16: // VOX gain
 	case 0x17: // anti-VOX gain
@@ -700,7 +1641,10 @@ func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 			s.removePendingCmd(&s.state.setPTT)
 			return false
 		}
-	case 1:
+	case 1: // 0 - tuner off, 1 - tuner in-line, 2 - tuning
+		s.state.tunerEnabled = d[1] != 0
+		statusLog.reportTunerEnabled(s.state.tunerEnabled)
+
 		if d[1] == 2 {
 			s.state.tune = true
 
@@ -715,6 +1659,7 @@ func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 					s.state.tuneTimeoutTimer.Stop()
 					s.state.tuneTimeoutTimer = nil
 				}
+				s.restorePwrAfterTuneIfNeeded()
 				_ = s.getVd()
 			}
 		}
@@ -724,6 +1669,10 @@ func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 			s.removePendingCmd(&s.state.setTune)
 			return false
 		}
+		if s.state.setTunerEnabled.pending {
+			s.removePendingCmd(&s.state.setTunerEnabled)
+			return false
+		}
 	}
 
 	if s.state.getTuneStatus.pending {
@@ -737,64 +1686,75 @@ func (s *civControlStruct) decodeTransmitStatus(d []byte) bool {
 	return true
 }
 
+// All meter values are 2 bytes of BCD after the subcmd, shorter frames (like our own echoed queries or
+// truncated frames on a noisy link) are not decoded.
 func (s *civControlStruct) decodeVdSWRS(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
 	subcmd := d[0]
 	data := d[1:]
 	switch subcmd {
+	case 0x01:
+		if len(data) < 1 {
+			return !s.state.getSquelchStatus.pending
+		}
+		s.state.squelchClosed = data[0] == 0
+		s.state.lastSquelchStatusAt = time.Now()
+		if s.state.getSquelchStatus.pending {
+			s.removePendingCmd(&s.state.getSquelchStatus)
+			return false
+		}
 	case 0x02:
 		if len(data) < 2 {
 			return !s.state.getS.pending
 		}
-		sValue := BCDToSLevel(data)
-		sStr := "S"
-		if sValue <= 9 {
-			sStr += fmt.Sprint(sValue)
-		} else {
-			sStr += "9+"
-			switch sValue {
-			case 10:
-				sStr += "10"
-			case 11:
-				sStr += "20"
-			case 12:
-				sStr += "30"
-			case 13:
-				sStr += "40"
-			case 14:
-				sStr += "40"
-			case 15:
-				sStr += "40"
-			case 16:
-				sStr += "40"
-			case 17:
-				sStr += "50"
-			case 18:
-				sStr += "50"
-			default:
-				sStr += "60"
-			}
-		}
+		sUnits := sMeter.rawToS(sMeter.decodeRaw(data))
+		sStr := sMeter.format(sUnits)
 		s.state.lastSReceivedAt = time.Now()
+		s.addSReading(sUnits)
 		statusLog.reportS(sStr)
 		if s.state.getS.pending {
 			s.removePendingCmd(&s.state.getS)
 			return false
 		}
 	case 0x12:
-		if len(d) < 3 {
+		if len(data) < 2 {
 			return !s.state.getSWR.pending
 		}
 		s.state.lastSWRReceivedAt = time.Now()
-		statusLog.reportSWR(BCDToSWR(data))
+		s.state.swr = BCDToSWR(data)
+		statusLog.reportSWR(s.state.swr)
 		if s.state.getSWR.pending {
 			s.removePendingCmd(&s.state.getSWR)
 			return false
 		}
+	case 0x13:
+		if len(data) < 2 {
+			return !s.state.getALC.pending
+		}
+		s.state.lastALCReceivedAt = time.Now()
+		statusLog.reportALC(levelToALCPercent(s.decodeLevelData(data)))
+		if s.state.getALC.pending {
+			s.removePendingCmd(&s.state.getALC)
+			return false
+		}
+	case 0x14:
+		if len(data) < 2 {
+			return !s.state.getComp.pending
+		}
+		s.state.lastCompReceivedAt = time.Now()
+		statusLog.reportComp(levelToCompDB(s.decodeLevelData(data)))
+		if s.state.getComp.pending {
+			s.removePendingCmd(&s.state.getComp)
+			return false
+		}
 	case 0x15:
-		if len(d) < 3 {
+		if len(data) < 2 {
 			return !s.state.getVd.pending
 		}
-		statusLog.reportVd(BCDToVd(data))
+		s.state.vd = BCDToVd(data)
+		statusLog.reportVd(s.state.vd)
 		if s.state.getVd.pending {
 			s.removePendingCmd(&s.state.getVd)
 			return false
@@ -804,6 +1764,9 @@ func (s *civControlStruct) decodeVdSWRS(d []byte) bool {
 }
 
 func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
 	subcmd := d[0]
 	data := d[1:]
 	switch subcmd {
@@ -812,7 +1775,7 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 			return !s.state.getPreamp.pending && !s.state.setPreamp.pending
 		}
 		s.state.preamp = int(data[0])
-		statusLog.reportPreamp(s.state.preamp)
+		statusLog.reportPreamp(s.state.preamp, s.isTwoStagePreamp())
 		if s.state.getPreamp.pending {
 			s.removePendingCmd(&s.state.getPreamp)
 			return false
@@ -844,6 +1807,20 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 			s.removePendingCmd(&s.state.setAGC)
 			return false
 		}
+	case 0x22:
+		if len(data) < 1 {
+			return !s.state.getNBEnabled.pending && !s.state.setNBEnabled.pending
+		}
+		s.state.nbEnabled = data[0] == 1
+		statusLog.reportNBEnabled(s.state.nbEnabled)
+		if s.state.getNBEnabled.pending {
+			s.removePendingCmd(&s.state.getNBEnabled)
+			return false
+		}
+		if s.state.setNBEnabled.pending {
+			s.removePendingCmd(&s.state.setNBEnabled)
+			return false
+		}
 	case 0x40:
 		if len(data) < 1 {
 			return !s.state.getNREnabled.pending && !s.state.setNREnabled.pending
@@ -862,6 +1839,87 @@ func (s *civControlStruct) decodePreampAGCNREnabled(d []byte) bool {
 			s.removePendingCmd(&s.state.setNREnabled)
 			return false
 		}
+	case 0x50:
+		if len(data) < 1 {
+			return !s.state.getDialLock.pending && !s.state.setDialLock.pending
+		}
+		s.state.dialLock = data[0] == 1
+		statusLog.reportDialLock(s.state.dialLock)
+		if s.state.getDialLock.pending {
+			s.removePendingCmd(&s.state.getDialLock)
+			return false
+		}
+		if s.state.setDialLock.pending {
+			s.removePendingCmd(&s.state.setDialLock)
+			return false
+		}
+	case 0x59:
+		if len(data) < 1 {
+			return !s.state.getDualWatch.pending && !s.state.setDualWatch.pending
+		}
+		s.state.dualWatch = data[0] == 1
+		statusLog.reportDualWatch(s.state.dualWatch)
+		if s.state.getDualWatch.pending {
+			s.removePendingCmd(&s.state.getDualWatch)
+			return false
+		}
+		if s.state.setDualWatch.pending {
+			s.removePendingCmd(&s.state.setDualWatch)
+			return false
+		}
+	}
+	return true
+}
+
+func (s *civControlStruct) decodeRITXIT(d []byte) bool {
+	if len(d) < 1 {
+		return true
+	}
+	subcmd := d[0]
+	data := d[1:]
+	switch subcmd {
+	case 0x00:
+		if len(data) < 3 {
+			return !s.state.getXIT.pending && !s.state.setXIT.pending
+		}
+		s.state.xitOffset = s.decodeOffsetData(data)
+		statusLog.reportXITOffset(s.state.xitOffset)
+		if s.state.getXIT.pending {
+			s.removePendingCmd(&s.state.getXIT)
+			return false
+		}
+		if s.state.setXIT.pending {
+			s.removePendingCmd(&s.state.setXIT)
+			return false
+		}
+	case 0x01:
+		if len(data) < 1 {
+			return !s.state.getRITEnabled.pending && !s.state.setRITEnabled.pending
+		}
+		s.state.ritEnabled = data[0] == 1
+		statusLog.reportRITEnabled(s.state.ritEnabled)
+		if s.state.getRITEnabled.pending {
+			s.removePendingCmd(&s.state.getRITEnabled)
+			return false
+		}
+		if s.state.setRITEnabled.pending {
+			s.removePendingCmd(&s.state.setRITEnabled)
+			return false
+		}
+	case 0x02:
+		if len(data) < 1 {
+			return !s.state.getXITEnabled.pending && !s.state.setXITEnabled.pending
+		}
+		s.state.xitEnabled = data[0] == 1
+		statusLog.reportXITEnabled(s.state.xitEnabled)
+		if s.state.getXITEnabled.pending {
+			s.removePendingCmd(&s.state.getXITEnabled)
+			return false
+		}
+		if s.state.setXITEnabled.pending {
+			s.removePendingCmd(&s.state.setXITEnabled)
+			return false
+		}
 	}
 	return true
 }
@@ -875,14 +1933,7 @@ func (s *civControlStruct) decodeVFOFreq(d []byte) bool {
 	default:
 		s.state.freq = f
 		statusLog.reportFrequency(s.state.freq)
-		s.state.bandIdx = len(civBands) - 1 // Set the band idx to GENE by default.
-		for i := range civBands {
-			if s.state.freq >= civBands[i].freqFrom && s.state.freq <= civBands[i].freqTo {
-				s.state.bandIdx = i
-				civBands[s.state.bandIdx].freq = s.state.freq
-				break
-			}
-		}
+		s.updateBand()
 
 		if s.state.getMainVFOFreq.pending {
 			s.removePendingCmd(&s.state.getMainVFOFreq)
@@ -894,6 +1945,7 @@ func (s *civControlStruct) decodeVFOFreq(d []byte) bool {
 		}
 	case 0x01:
 		s.state.subFreq = f
+		s.state.lastSubVFOFreqReceivedAt = time.Now()
 		statusLog.reportSubFrequency(s.state.subFreq)
 		if s.state.getSubVFOFreq.pending {
 			s.removePendingCmd(&s.state.getSubVFOFreq)
@@ -907,46 +1959,48 @@ func (s *civControlStruct) decodeVFOFreq(d []byte) bool {
 	return true
 }
 
+// The answer is the VFO, the mode, the data mode and the filter.
 func (s *civControlStruct) decodeVFOMode(d []byte) bool {
-	if len(d) < 2 {
-		return !s.state.getMainVFOMode.pending && !s.state.getSubVFOMode.pending && !s.state.setSubVFOMode.pending
+	if len(d) < 4 {
+		return !s.state.getMainVFOMode.pending && !s.state.getSubVFOMode.pending && !s.state.setSubVFOMode.pending &&
+			!s.state.setMainVFOMode.pending
 	}
 
-	operatingModeIdx := -1
-	for i := range civOperatingModes {
-		if civOperatingModes[i].code == d[1] {
-			operatingModeIdx = i
-			break
-		}
-	}
-	var dataMode bool
-	if len(d) > 2 && d[2] != 0 {
-		dataMode = true
-	}
-	filterIdx := -1
-	if len(d) > 3 {
-		filterIdx = s.decodeFilterValueToFilterIdx(d[3])
-	}
+	operatingModeIdx := s.findOperatingModeIdx(d[1])
+	updateMode := operatingModeIdx >= 0 || !keepModeOnUnknown
+	dataMode := d[2] != 0
+	filterIdx := s.decodeFilterValueToFilterIdx(d[3])
 
 	switch d[0] {
 	default:
-		s.state.operatingModeIdx = operatingModeIdx
-		s.state.dataMode = dataMode
-		if filterIdx >= 0 {
-			s.state.filterIdx = filterIdx
+		prevOperatingModeIdx := s.state.operatingModeIdx
+		if updateMode {
+			s.state.operatingModeIdx = operatingModeIdx
+			s.state.modeCode = d[1]
 		}
-		statusLog.reportMode(civOperatingModes[s.state.operatingModeIdx].name, s.state.dataMode,
+		s.applyModeSettingsIfNeeded(prevOperatingModeIdx)
+		s.state.dataMode = dataMode
+		s.state.filterIdx = filterIdx
+		statusLog.reportMode(s.getModeDisplayName(s.state.operatingModeIdx, s.state.modeCode), s.state.dataMode,
 			civFilters[s.state.filterIdx].name)
+		s.updateFilterWidth()
 
 		if s.state.getMainVFOMode.pending {
 			s.removePendingCmd(&s.state.getMainVFOMode)
 			return false
 		}
+		if s.state.setMainVFOMode.pending {
+			s.removePendingCmd(&s.state.setMainVFOMode)
+			return false
+		}
 	case 0x01:
-		s.state.subOperatingModeIdx = operatingModeIdx
+		if updateMode {
+			s.state.subOperatingModeIdx = operatingModeIdx
+			s.state.subModeCode = d[1]
+		}
 		s.state.subDataMode = dataMode
 		s.state.subFilterIdx = filterIdx
-		statusLog.reportSubMode(civOperatingModes[s.state.subOperatingModeIdx].name, s.state.subDataMode,
+		statusLog.reportSubMode(s.getModeDisplayName(s.state.subOperatingModeIdx, s.state.subModeCode), s.state.subDataMode,
 			civFilters[s.state.subFilterIdx].name)
 
 		if s.state.getSubVFOMode.pending {
@@ -961,13 +2015,153 @@ func (s *civControlStruct) decodeVFOMode(d []byte) bool {
 	return true
 }
 
+// Returns the index of the mode code in civOperatingModes, or -1 if it's unknown. Unknown codes (newer
+// firmware, DV sub-modes) are logged once, so they can be added later.
+func (s *civControlStruct) findOperatingModeIdx(code byte) int {
+	for i := range civOperatingModes {
+		if civOperatingModes[i].code == code {
+			return i
+		}
+	}
+
+	if s.state.unknownModeCodes == nil {
+		s.state.unknownModeCodes = make(map[byte]bool)
+	}
+	if !s.state.unknownModeCodes[code] {
+		s.state.unknownModeCodes[code] = true
+		log.Error(fmt.Sprintf("radio reported unknown operating mode 0x%02x", code))
+	}
+	return -1
+}
+
+// Unknown modes are displayed with their raw code, like MODE?0x17.
+func (s *civControlStruct) getModeDisplayName(modeIdx int, modeCode byte) string {
+	if modeIdx < 0 {
+		return fmt.Sprintf("MODE?0x%02x", modeCode)
+	}
+	return civOperatingModes[modeIdx].name
+}
+
+// returns the name of the main VFO's operating mode, empty if it's not known yet
+func (s *civControlStruct) getOperatingModeName() string {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	if s.state.operatingModeIdx < 0 {
+		return ""
+	}
+	return civOperatingModes[s.state.operatingModeIdx].name
+}
+
+// returns true if the main VFO is in FM mode
+func (s *civControlStruct) isFMMode() bool {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	return s.state.operatingModeIdx >= 0 && civOperatingModes[s.state.operatingModeIdx].name == "FM"
+}
+
+// returns true if the radio's squelch is closed, used for gating the local audio monitor
+func (s *civControlStruct) isSquelchClosed() bool {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	return s.state.squelchClosed
+}
+
+type sReading struct {
+	at time.Time
+	s  float64
+}
+
+func (s *civControlStruct) addSReading(sUnits float64) {
+	now := time.Now()
+	s.state.sReadings = append(s.state.sReadings, sReading{at: now, s: sUnits})
+	for len(s.state.sReadings) > 1 && now.Sub(s.state.sReadings[0].at) > sPeakWindow {
+		s.state.sReadings = s.state.sReadings[1:]
+	}
+}
+
+// Returns a signal report line for logging the station we're listening to: the UTC time, the frequency
+// in kHz, the mode, the RS(T) report from the peak S level of the last second, and the S level.
+func (s *civControlStruct) getSignalReport() (string, error) {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	if s.state.freq == 0 || s.state.operatingModeIdx < 0 || len(s.state.sReadings) == 0 {
+		return "", errors.New("frequency, mode or S level is not known yet")
+	}
+	peak := s.state.sReadings[0].s
+	for _, r := range s.state.sReadings[1:] {
+		if r.s > peak {
+			peak = r.s
+		}
+	}
+
+	strength := int(peak)
+	if strength < 1 {
+		strength = 1
+	} else if strength > 9 {
+		strength = 9
+	}
+	mode := civOperatingModes[s.state.operatingModeIdx]
+	rst := fmt.Sprint("5", strength)
+	if s.isCWOrRTTYMode(mode.code) {
+		rst += "9"
+	}
+
+	return fmt.Sprintf("%s %.1f %s RST %s (%s)", time.Now().UTC().Format("2006-01-02 1504Z"),
+		float64(s.state.freq)/1000, mode.name, rst, sMeter.format(peak)), nil
+}
+
+// called by the DTMF detector when a digit is received
+func (s *civControlStruct) reportDTMF(digit byte) {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	s.state.lastDTMF += string(digit)
+	if len(s.state.lastDTMF) > dtmfMaxLastDigits {
+		s.state.lastDTMF = s.state.lastDTMF[len(s.state.lastDTMF)-dtmfMaxLastDigits:]
+	}
+	statusLog.reportDTMF(s.state.lastDTMF)
+}
+
 // better name might be prepCmd, loadCmd, or newCmd... or at least expand to initializeCmd
 func (s *civControlStruct) initCmd(cmd *civCmd, name string, data []byte) {
+	// A set command may be sent again before the radio confirmed the previous send, or before it left the
+	// send queue.
+	var unconfirmed int
+	var queued bool
+	if s.getPendingCmdIndex(cmd) >= 0 {
+		unconfirmed = cmd.unconfirmed
+		queued = cmd.queued
+	}
 	*cmd = civCmd{}
+	cmd.unconfirmed = unconfirmed
+	cmd.queued = queued
 	cmd.name = name
 	cmd.cmd = data // this is the cmd + subcmd + data to send
 }
 
+// Returns the commands which are waiting for an answer from the radio, with how long they have been
+// pending. If a value on the status bar is frozen, this shows if its command is stuck unanswered.
+func (s *civControlStruct) getPendingCmdsStr() string {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	if len(s.state.pendingCmds) == 0 {
+		return "pending CI-V commands: none"
+	}
+	str := fmt.Sprint("pending CI-V commands (", len(s.state.pendingCmds), "):")
+	for i, cmd := range s.state.pendingCmds {
+		if i > 0 {
+			str += ","
+		}
+		str += fmt.Sprint(" ", cmd.name, " ", time.Since(cmd.sentAt).Round(time.Millisecond))
+	}
+	return str
+}
+
 func (s *civControlStruct) getPendingCmdIndex(cmd *civCmd) int {
 	for i := range s.state.pendingCmds {
 		if cmd == s.state.pendingCmds[i] {
@@ -977,15 +2171,56 @@ func (s *civControlStruct) getPendingCmdIndex(cmd *civCmd) int {
 	return -1
 }
 
+// Called by the decoders when the radio answered a query. Set commands are only removed by the OK/NG
+// answer of the radio (see decodeOK()), not by the decode of their echo or a state change sent by the
+// radio, as then the OK/NG answer would be matched to another pending command.
 func (s *civControlStruct) removePendingCmd(cmd *civCmd) {
+	if isSetCmd(cmd) {
+		return
+	}
+	s.confirmPendingCmd(cmd)
+}
+
+// Called when the radio answered the command, the command latency is updated with the answer time.
+func (s *civControlStruct) confirmPendingCmd(cmd *civCmd) {
+	if !cmd.retried && s.getPendingCmdIndex(cmd) >= 0 {
+		// The average is started with the first answer, so it doesn't start from half of the latency.
+		if s.state.cmdLatency == 0 {
+			s.state.cmdLatency = time.Since(cmd.sentAt)
+		} else {
+			s.state.cmdLatency += time.Since(cmd.sentAt)
+			s.state.cmdLatency /= 2
+		}
+		statusLog.reportCIVLatency(s.state.cmdLatency)
+	}
+	s.dropPendingCmd(cmd)
+}
+
+// Removes the command without updating the command latency, used when the command is refused or
+// it has timed out.
+func (s *civControlStruct) dropPendingCmd(cmd *civCmd) {
 	cmd.pending = false
+	cmd.unconfirmed = 0
 	index := s.getPendingCmdIndex(cmd)
 	if index < 0 {
 		return
 	}
+
 	s.state.pendingCmds[index] = s.state.pendingCmds[len(s.state.pendingCmds)-1]
 	s.state.pendingCmds[len(s.state.pendingCmds)-1] = nil
 	s.state.pendingCmds = s.state.pendingCmds[:len(s.state.pendingCmds)-1]
+	s.reportPendingSetCmds()
+}
+
+// Updates the count of set commands which are not confirmed by the radio yet on the status bar.
+func (s *civControlStruct) reportPendingSetCmds() {
+	var count int
+	for _, cmd := range s.state.pendingCmds {
+		if isSetCmd(cmd) {
+			count++
+		}
+	}
+	statusLog.reportPendingCIVSetCmds(count)
 }
 
 func (s *civControlStruct) sendCmd(cmd *civCmd) error {
@@ -996,23 +2231,229 @@ func (s *civControlStruct) sendCmd(cmd *civCmd) error {
 
 	cmd.pending = true
 	cmd.sentAt = time.Now()
+	// A retry replaces a lost send, so only one answer is expected for them.
+	if isSetCmd(cmd) && !cmd.retried {
+		cmd.unconfirmed++
+	}
 
 	// add this cmd request to the list of pending commands we'll need to process returned data for
 	//   each cmd request is a pointer to a civCmd object, so this is check of a specfic request rather than just name of a command sent
 	if s.getPendingCmdIndex(cmd) < 0 {
 		s.state.pendingCmds = append(s.state.pendingCmds, cmd)
+		s.reportPendingSetCmds()
 		select {
 		case s.newPendingCmdAdded <- true:
 		default:
 		}
 	}
 
+	if s.sendQueue != nil {
+		select {
+		case s.sendQueue <- cmd:
+			cmd.queued = true
+		default:
+			// The command stays pending, so it will be retried.
+			log.Debug("CI-V send queue is full, dropping cmd ", cmd.name)
+		}
+		return nil
+	}
+
 	// now actually send it to the serial stream
 	return s.st.send(cmd.cmd)
 }
 
-func prepPacket(command string, data []byte) (pkt []byte) {
-	pkt = append([]byte{0xfe, 0xfe}, []byte{civAddress, controllerAddress}...)
+// Pacing commands, as the radio may miss some if they are sent too quickly. The sleeping is done here,
+// so the state mutex is not held while waiting.
+func (s *civControlStruct) sendLoop(st *serialStream) {
+	var lastSentAt time.Time
+	for {
+		select {
+		case cmd := <-s.sendQueue:
+			if wait := civCmdGap - time.Since(lastSentAt); wait > 0 {
+				time.Sleep(wait)
+			}
+			lastSentAt = time.Now()
+			s.state.mutex.Lock()
+			d := cmd.cmd
+			cmd.queued = false
+			cmd.sentAt = lastSentAt
+			// Waking up loop(), so the retry timeout of the command is started.
+			select {
+			case s.newPendingCmdAdded <- true:
+			default:
+			}
+			s.state.mutex.Unlock()
+			if err := st.send(d); err != nil {
+				reportError(fmt.Errorf("can't send CI-V cmd: %w", err))
+			}
+		case <-s.sendLoopDeinitNeeded:
+			s.sendLoopDeinitFinished <- true
+			return
+		}
+	}
+}
+
+// checks if the received packet is an answer for the given sent command: the radio answers either
+// with OK/NG, or with the same cmd (and subcmd) as the sent one. Our own echoed packet is ignored.
+func (s *civControlStruct) isAnswerFor(cmd *civCmd, d []byte) bool {
+	if d[2] == civAddress || d[3] != civAddress {
+		return false
+	}
+	if d[4] == OK || d[4] == NG {
+		return true
+	}
+	cmdSeq := CIV[cmd.name].cmdSeq
+	return len(d) > 4+len(cmdSeq) && bytes.Equal(d[4:4+len(cmdSeq)], cmdSeq)
+}
+
+// sends an arbitrary command from the CIV map with the given data, returns the sent packet
+func (s *civControlStruct) injectCmd(name string, data []byte) ([]byte, error) {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	s.initCmd(&s.state.injectedCmd, name, prepPacket(name, data))
+	return s.state.injectedCmd.cmd, s.sendCmd(&s.state.injectedCmd)
+}
+
+// counts frames from unknown addresses, and logs an error the first time we see a new address
+func (s *civControlStruct) reportUnknownDevice(addr byte) {
+	s.state.unknownDeviceFrames++
+	if s.state.unknownDeviceAddresses == nil {
+		s.state.unknownDeviceAddresses = make(map[byte]bool)
+	}
+	if !s.state.unknownDeviceAddresses[addr] {
+		s.state.unknownDeviceAddresses[addr] = true
+		log.Error(fmt.Sprintf("another device (%02x) is on the CI-V bus, its frames are ignored "+
+			"(transceiver is %02x, we are %02x, check the -c and -z args)", addr, civAddress, controllerAddress))
+	}
+	statusLog.reportUnknownCIVFrames(s.state.unknownDeviceFrames)
+}
+
+// the answer for a raw command is OK/NG, or starts with the whole sent command (cmd, subcmd and the
+// parameters of a query, like a menu item number). As the radio answers in order, OK/NG belongs to the
+// raw command only if it's the earliest sent pending (set) command, see decodeOK() and decodeNG(). Our
+// own echoed packet is ignored.
+func (s *civControlStruct) isRawCmdAnswer(d []byte) bool {
+	if d[2] == civAddress || d[3] != civAddress {
+		return false
+	}
+	switch d[4] {
+	case OK:
+		return s.getEarliestPendingCmd(true) == &s.state.rawCmd
+	case NG:
+		return s.getEarliestPendingCmd(false) == &s.state.rawCmd
+	}
+	sent := s.state.rawCmd.cmd[4 : len(s.state.rawCmd.cmd)-1]
+	return len(d)-1 > 4+len(sent) && bytes.Equal(d[4:4+len(sent)], sent)
+}
+
+// Switches to another transceiver on the CI-V bus without restarting. The CI-V control is reinitialized,
+// so all state is queried from the new radio.
+func switchCIVAddress(addr byte) error {
+	if addr == 0x00 || addr >= 0xe0 || addr == controllerAddress {
+		return fmt.Errorf("%02x can't be a transceiver address", addr)
+	}
+	st := civControl.st
+	if st == nil {
+		return errors.New("serial stream is not running")
+	}
+	if addr == civAddress {
+		return nil
+	}
+
+	log.Print(fmt.Sprintf("switching CI-V address from %02x to %02x", civAddress, addr))
+	civControl.deinit()
+	civControl.reset()
+	civControl.state.mutex.Lock()
+	civAddress = addr
+	civControl.state.mutex.Unlock()
+	if err := civControl.init(st); err != nil {
+		return err
+	}
+
+	// Not using selfTest() here, as a wrong address shouldn't quit, the user can enter another one.
+	go func() {
+		if _, err := civControl.sendRawCIV([]byte{0x19, 0x00}); err != nil {
+			log.Error(fmt.Sprintf("no CI-V response from the radio at %02x: %v", addr, err))
+		}
+	}()
+	return nil
+}
+
+// The radio's communication is only checked on the first connection, the radio may be turned off while
+// we are reconnecting later.
+var civSelfTestPassed bool
+
+// Queries the radio's ID, so we can warn the user if it doesn't respond (for example because of a wrong
+// CI-V address) instead of displaying an empty status bar. It's called before init(), so the query is
+// sent right away instead of waiting behind the init queries in the send queue, and it's not retried by
+// the CI-V control loop, so it's tried a few times here.
+func (s *civControlStruct) selfTest(st *serialStream) error {
+	if civSelfTestPassed {
+		return nil
+	}
+	s.st = st
+	var err error
+	for i := 0; i < selfTestTries; i++ {
+		if _, err = s.sendRawCIVWithTimeout([]byte{0x19, 0x00}, selfTestTimeout); err == nil {
+			civSelfTestPassed = true
+			return nil
+		}
+	}
+	err = fmt.Errorf("no CI-V response from the radio (%v) - check the CI-V address (-c, currently %02x) "+
+		"and the radio's CI-V settings", err, civAddress)
+	reportFatalError(err)
+	return err
+}
+
+// sends the given CI-V command sequence (cmd, optional subcmd and data, without the preamble, the
+// addresses and the end of message byte) and waits for the answer, which is returned as a whole packet.
+// This can be used for commands which are not in the CIV map.
+func (s *civControlStruct) sendRawCIV(cmd []byte) ([]byte, error) {
+	return s.sendRawCIVWithTimeout(cmd, rawCmdTimeout)
+}
+
+func (s *civControlStruct) sendRawCIVWithTimeout(cmd []byte, timeout time.Duration) ([]byte, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("no cmd given")
+	}
+
+	s.state.mutex.Lock()
+	if s.st == nil {
+		s.state.mutex.Unlock()
+		return nil, errors.New("serial stream is not running")
+	}
+	if s.state.rawCmd.pending {
+		s.state.mutex.Unlock()
+		return nil, errors.New("another raw cmd is already pending")
+	}
+	p := append([]byte{0xfe, 0xfe, civAddress, controllerAddress}, cmd...)
+	p = append(p, 0xfd)
+	if debugPackets {
+		debugPacket("raw", p)
+	}
+	s.initCmd(&s.state.rawCmd, "raw", p)
+	answerChan := make(chan []byte, 1)
+	s.state.rawCmdAnswerChan = answerChan
+	err := s.sendCmd(&s.state.rawCmd)
+	s.state.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case d := <-answerChan:
+		return d, nil
+	case <-time.After(timeout):
+		s.state.mutex.Lock()
+		s.dropPendingCmd(&s.state.rawCmd)
+		s.state.mutex.Unlock()
+		return nil, errors.New("timeout waiting for answer")
+	}
+}
+
+func prepPacket(command string, data []byte) (pkt []byte) {
+	pkt = append([]byte{0xfe, 0xfe}, []byte{civAddress, controllerAddress}...)
 	pkt = append(pkt, CIV[command].cmdSeq...)
 	pkt = append(pkt, data...)
 	pkt = append(pkt, []byte{0xfd}...)
@@ -1064,17 +2505,6 @@ func BCDAsPct(bcd []byte) (pct int) {
 }
 */
 
-func BCDToSLevel(bcd []byte) (sLevel int) {
-	// BCD to S-level
-	//  0000 => S0
-	//  0120 => S9
-	//  0241 => S9 + 60dB
-	//  we want 17  S-levels, 10 for S0-9, plus 7 for each +10dB, number them 1 - 18
-	fullScale := float64(241) // FWIW, 241 = b11110001
-	sLevel = int(float64(BCDToDec(bcd))/fullScale) * 18
-	return
-}
-
 func BCDToSWR(bcd []byte) (SWR float64) {
 	// BCD to SWR - note that this isn't linear
 	//	0000 => 1.0
@@ -1086,6 +2516,24 @@ func BCDToSWR(bcd []byte) (SWR float64) {
 	return
 }
 
+// the ALC meter reading in percent of the ALC zone, above 100% the audio input is overdriven
+func levelToALCPercent(level int) float64 {
+	//  0000 => start of the ALC zone
+	//  0120 => end of the ALC zone
+	return float64(level) / 120 * 100
+}
+
+// the speech compressor meter reading in dB, this isn't linear
+func levelToCompDB(level int) float64 {
+	//  0000 => 0dB
+	//  0130 => 15dB
+	//  0241 => 30dB
+	if level <= 130 {
+		return float64(level) / 130 * 15
+	}
+	return 15 + float64(level-130)/(241-130)*15
+}
+
 func BCDToVd(bcd []byte) (Vd float64) {
 	// BCD to Vd
 	//  0000 => 0v
@@ -1107,16 +2555,44 @@ func (s *civControlStruct) getDigit(v uint, decade int) byte {
 	return byte(uint(asDecimal) % 10)
 }
 
-func (s *civControlStruct) decodeFreqData(d []byte) (f uint) {
-	var pos int
+func (s *civControlStruct) decodeFreqData(d []byte) (f uint64) {
+	mul := uint64(1)
 	for _, v := range d {
-		s1 := v & 0x0f
-		s2 := v >> 4
-		f += uint(s1) * uint(math.Pow(10, float64(pos)))
-		pos++
-		f += uint(s2) * uint(math.Pow(10, float64(pos)))
-		pos++
+		f += uint64(v&0x0f) * mul
+		mul *= 10
+		f += uint64(v>>4) * mul
+		mul *= 10
+	}
+	return
+}
+
+// decodes the RIT/XIT offset: 2 bytes of BCD (1Hz/10Hz, 100Hz/1kHz) and a direction byte (0 = +, 1 = -)
+func (s *civControlStruct) decodeOffsetData(d []byte) int {
+	offset := int(s.decodeFreqData(d[:2]))
+	if d[2] == 1 {
+		offset = -offset
 	}
+	return offset
+}
+
+// decodes a 0000-0255 level sent as 2 bytes of BCD
+func (s *civControlStruct) decodeLevelData(d []byte) int {
+	return int(d[0]>>4)*1000 + int(d[0]&0x0f)*100 + int(d[1]>>4)*10 + int(d[1]&0x0f)
+}
+
+func (s *civControlStruct) encodeLevelData(level int) []byte {
+	v := uint(level)
+	return []byte{s.getDigit(v, 3)<<4 | s.getDigit(v, 2), s.getDigit(v, 1)<<4 | s.getDigit(v, 0)}
+}
+
+func (s *civControlStruct) encodeOffsetData(offset int) (b [3]byte) {
+	if offset < 0 {
+		b[2] = 1
+		offset = -offset
+	}
+	v := uint(offset)
+	b[0] = s.getDigit(v, 1)<<4 | s.getDigit(v, 0)
+	b[1] = s.getDigit(v, 3)<<4 | s.getDigit(v, 2)
 	return
 }
 
@@ -1163,6 +2639,20 @@ func (s *civControlStruct) setSQL(level int) error {
 	return s.sendCmd(&s.state.setSQL)
 }
 
+func (s *civControlStruct) setPBT(pbt1, pbt2 int) error {
+	s.initCmd(&s.state.setPBT1, "setPBT1", prepPacket("setPBT1", s.encodeLevelData(pbt1)))
+	if err := s.sendCmd(&s.state.setPBT1); err != nil {
+		return err
+	}
+	s.initCmd(&s.state.setPBT2, "setPBT2", prepPacket("setPBT2", s.encodeLevelData(pbt2)))
+	return s.sendCmd(&s.state.setPBT2)
+}
+
+// Sets both PBT knobs to their center position, so the passband equals the IF filter width.
+func (s *civControlStruct) resetPBT() error {
+	return s.setPBT(128, 128)
+}
+
 func (s *civControlStruct) incSQL() error {
 	if s.state.sqlLevel < 255 {
 		return s.setSQL(s.state.sqlLevel + 1)
@@ -1177,6 +2667,65 @@ func (s *civControlStruct) decSQL() error {
 	return nil
 }
 
+// Returns true if the squelch gates the audio in the main VFO's mode, so the sensitivity is set with the
+// squelch. In the other modes it's set with the RF gain, like with the radio's RF/SQL control on AUTO.
+func (s *civControlStruct) isSQLSensitivityMode() bool {
+	if s.state.operatingModeIdx < 0 {
+		return false
+	}
+	switch civOperatingModes[s.state.operatingModeIdx].name {
+	case "AM", "FM", "WFM", "DV":
+		return true
+	}
+	return false
+}
+
+// Returns the combined RF gain and squelch sensitivity level (0-255), 255 means full RF gain with the
+// squelch open.
+func (s *civControlStruct) getSensitivity() int {
+	if s.isSQLSensitivityMode() {
+		return 255 - s.state.sqlLevel
+	}
+	return s.state.rfGainLevel
+}
+
+func (s *civControlStruct) reportSensitivity() {
+	statusLog.reportSensitivity(s.getSensitivity())
+}
+
+// Sets the squelch or the RF gain depending on the mode, and sets the other one so it doesn't interfere:
+// full RF gain in squelched modes, and an open squelch in the other modes.
+func (s *civControlStruct) setSensitivity(level int) error {
+	if level < 0 {
+		level = 0
+	} else if level > 255 {
+		level = 255
+	}
+
+	if s.isSQLSensitivityMode() {
+		if s.state.rfGainLevel != 255 {
+			if err := s.setRFGain(255); err != nil {
+				return err
+			}
+		}
+		return s.setSQL(255 - level)
+	}
+	if s.state.sqlLevel != 0 {
+		if err := s.setSQL(0); err != nil {
+			return err
+		}
+	}
+	return s.setRFGain(level)
+}
+
+func (s *civControlStruct) incSensitivity() error {
+	return s.setSensitivity(s.getSensitivity() + sensitivityStep)
+}
+
+func (s *civControlStruct) decSensitivity() error {
+	return s.setSensitivity(s.getSensitivity() - sensitivityStep)
+}
+
 func (s *civControlStruct) setNR(level int) error {
 	if !s.state.nrEnabled {
 		if err := s.toggleNR(); err != nil {
@@ -1187,6 +2736,50 @@ func (s *civControlStruct) setNR(level int) error {
 	return s.sendCmd(&s.state.setNR)
 }
 
+func (s *civControlStruct) setNB(level int) error {
+	if !s.state.nbEnabled {
+		if err := s.toggleNB(); err != nil {
+			return err
+		}
+	}
+	s.initCmd(&s.state.setNB, "setNB", prepPacket("setNB", encodeForSend(level)))
+	return s.sendCmd(&s.state.setNB)
+}
+
+func (s *civControlStruct) incNB() error {
+	if s.state.nbLevel < 255 {
+		return s.setNB(s.state.nbLevel + 1)
+	}
+	return nil
+}
+
+func (s *civControlStruct) decNB() error {
+	if s.state.nbLevel > 0 {
+		return s.setNB(s.state.nbLevel - 1)
+	}
+	return nil
+}
+
+// sets the noise blanker depth (1-10) and width (1-100)
+func (s *civControlStruct) setNBDepthWidth(depth, width int) error {
+	if depth < 1 || depth > 10 {
+		return errors.New("nb depth must be 1-10")
+	}
+	if width < 1 || width > 100 {
+		return errors.New("nb width must be 1-100")
+	}
+	s.initCmd(&s.state.setNBDepth, "setNBDepth", prepPacket("setNBDepth", []byte{byte(depth - 1)}))
+	if err := s.sendCmd(&s.state.setNBDepth); err != nil {
+		return err
+	}
+	level := ((width-1)*255 + 49) / 99
+	s.initCmd(&s.state.setNBWidth, "setNBWidth", prepPacket("setNBWidth", encodeForSend(level)))
+	if err := s.sendCmd(&s.state.setNBWidth); err != nil {
+		return err
+	}
+	return s.getNBDepthWidth()
+}
+
 func (s *civControlStruct) incNR() error {
 	if s.state.nrLevel < 255 {
 		return s.setNR(s.state.nrLevel + 1)
@@ -1201,62 +2794,97 @@ func (s *civControlStruct) decNR() error {
 	return nil
 }
 
-func (s *civControlStruct) incFreq() error {
-	return s.setMainVFOFreq(s.state.freq + s.state.ts)
+func (s *civControlStruct) incFreq(steps uint) error {
+	_, maxFreq := getFreqRange()
+	f := s.state.freq + uint64(steps*s.state.ts)
+	if f > maxFreq {
+		f = maxFreq
+	}
+	return s.sendMainVFOFreq(f)
+}
+
+func (s *civControlStruct) decFreq(steps uint) error {
+	minFreq, _ := getFreqRange()
+	// the frequency is unsigned, so it's checked before subtracting to avoid an underflow
+	if s.state.freq < minFreq+uint64(steps*s.state.ts) {
+		return s.sendMainVFOFreq(minFreq)
+	}
+	return s.sendMainVFOFreq(s.state.freq - uint64(steps*s.state.ts))
+}
+
+// returns the frequency range of the radio
+func getFreqRange() (min, max uint64) {
+	if civAddress == ic905CIVAddress {
+		return ic905MinFreq, ic905MaxFreq
+	}
+	return civMinFreq, civMaxFreq
 }
 
-func (s *civControlStruct) decFreq() error {
-	return s.setMainVFOFreq(s.state.freq - s.state.ts)
+// returns the number of BCD bytes the radio uses for frequencies
+func getFreqDataLen() int {
+	if civAddress == ic905CIVAddress {
+		return ic905FreqDataLen
+	}
+	return defaultFreqDataLen
 }
 
-func (s *civControlStruct) encodeFreqData(f uint) (b [5]byte) {
-	// min/max valid frequency: 30kHZ, 470MHz
+// Encodes the frequency as BCD, least significant byte first. Leading zeros are kept, as the radio
+// expects a fixed length.
+func (s *civControlStruct) encodeFreqData(f uint64) (b []byte) {
+	// min/max valid frequency: see getFreqRange()
 	// NOTE: there are no software sanity checks on the value.  TODO: add them here
-	v0 := s.getDigit(f, 9)
-	v1 := s.getDigit(f, 8)
-	b[4] = v0<<4 | v1
-	v0 = s.getDigit(f, 7)
-	v1 = s.getDigit(f, 6)
-	b[3] = v0<<4 | v1
-	v0 = s.getDigit(f, 5)
-	v1 = s.getDigit(f, 4)
-	b[2] = v0<<4 | v1
-	v0 = s.getDigit(f, 3)
-	v1 = s.getDigit(f, 2)
-	b[1] = v0<<4 | v1
-	v0 = s.getDigit(f, 1)
-	v1 = s.getDigit(f, 0)
-	b[0] = v0<<4 | v1
+	b = make([]byte, getFreqDataLen())
+	for i := range b {
+		b[i] = byte(f/10%10)<<4 | byte(f%10)
+		f /= 100
+	}
 	return
 }
 
-func (s *civControlStruct) setMainVFOFreq(f uint) error {
-	asBCD := s.encodeFreqData(f) // encodes to [5]byte to ensure leading zero's aren't lost
-	s.initCmd(&s.state.setMainVFOFreq, "setMainVFOFreq", prepPacket("setMainVFOFreq", asBCD[:]))
+// The current frequency and mode are remembered, so swapLastFreq() can jump back to them.
+func (s *civControlStruct) setMainVFOFreq(f uint64) error {
+	if s.state.freq != 0 && s.state.freq != f {
+		s.state.lastFreq = s.state.freq
+		s.state.lastModeIdx = s.state.operatingModeIdx
+		s.state.lastFilterIdx = s.state.filterIdx
+	}
+	return s.sendMainVFOFreq(f)
+}
+
+// Tuning steps don't use setMainVFOFreq(), so they don't overwrite the last frequency.
+func (s *civControlStruct) sendMainVFOFreq(f uint64) error {
+	asBCD := s.encodeFreqData(f)
+	s.initCmd(&s.state.setMainVFOFreq, "setMainVFOFreq", prepPacket("setMainVFOFreq", asBCD))
 	return s.sendCmd(&s.state.setMainVFOFreq)
 }
 
-func (s *civControlStruct) setSubVFOFreq(f uint) error {
-	asBCD := s.encodeFreqData(f) // encodes to [5]byte to ensure leading zero's aren't lost
-	s.initCmd(&s.state.setSubVFOFreq, "setSubVFOFreq", prepPacket("setSubVFOFreq", asBCD[:]))
-	return s.sendCmd(&s.state.setSubVFOFreq)
+func (s *civControlStruct) setSubVFOFreq(f uint64) error {
+	asBCD := s.encodeFreqData(f)
+	s.initCmd(&s.state.setSubVFOFreq, "setSubVFOFreq", prepPacket("setSubVFOFreq", asBCD))
+	if err := s.sendCmd(&s.state.setSubVFOFreq); err != nil {
+		return err
+	}
+	// Reading it back right away, so the sub VFO/split display doesn't wait for the next poll.
+	return s.getSubVFOFreq()
 }
 
+// The operating mode state is only updated when the new mode is decoded, so mode change handlers can
+// compare it to the previous mode.
 func (s *civControlStruct) incOperatingMode() error {
-	s.state.operatingModeIdx++
-	if s.state.operatingModeIdx >= len(civOperatingModes) {
-		s.state.operatingModeIdx = 0
+	idx := s.state.operatingModeIdx + 1
+	if idx >= len(civOperatingModes) {
+		idx = 0
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return civControl.setOperatingModeAndFilter(civOperatingModes[idx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
 func (s *civControlStruct) decOperatingMode() error {
-	s.state.operatingModeIdx--
-	if s.state.operatingModeIdx < 0 {
-		s.state.operatingModeIdx = len(civOperatingModes) - 1
+	idx := s.state.operatingModeIdx - 1
+	if idx < 0 {
+		idx = len(civOperatingModes) - 1
 	}
-	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
+	return civControl.setOperatingModeAndFilter(civOperatingModes[idx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
@@ -1265,6 +2893,9 @@ func (s *civControlStruct) incFilter() error {
 	if s.state.filterIdx >= len(civFilters) {
 		s.state.filterIdx = 0
 	}
+	if s.state.operatingModeIdx < 0 {
+		return errors.New("operating mode is unknown")
+	}
 	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
@@ -1274,60 +2905,530 @@ func (s *civControlStruct) decFilter() error {
 	if s.state.filterIdx < 0 {
 		s.state.filterIdx = len(civFilters) - 1
 	}
+	if s.state.operatingModeIdx < 0 {
+		return errors.New("operating mode is unknown")
+	}
 	return civControl.setOperatingModeAndFilter(civOperatingModes[s.state.operatingModeIdx].code,
 		civFilters[s.state.filterIdx].code)
 }
 
-func (s *civControlStruct) setOperatingModeAndFilter(modeCode, filterCode byte) error {
-	s.initCmd(&s.state.setMode, "setMode", prepPacket("setMode", []byte{modeCode, filterCode}))
-	if err := s.sendCmd(&s.state.setMode); err != nil {
-		return err
+func (s *civControlStruct) isCWMode(modeCode byte) bool {
+	return modeCode == 0x03 || modeCode == 0x07 // CW, CW-R
+}
+
+func (s *civControlStruct) isSSBOrCWMode(modeCode byte) bool {
+	switch modeCode {
+	case 0x00, 0x01, 0x03, 0x07: // LSB, USB, CW, CW-R
+		return true
+	}
+	return false
+}
+
+func (s *civControlStruct) isCWOrRTTYMode(modeCode byte) bool {
+	switch modeCode {
+	case 0x03, 0x04, 0x07, 0x08: // CW, RTTY, CW-R, RTTY-R
+		return true
+	}
+	return false
+}
+
+// If the cw-rtty-filter option is set, then switching to CW or RTTY from another mode selects the given
+// filter instead of keeping the filter used in the previous mode.
+func (s *civControlStruct) setOperatingModeAndFilter(modeCode, filterCode byte) error {
+	if cwRTTYFilterIdx >= 0 && s.isCWOrRTTYMode(modeCode) && s.state.operatingModeIdx >= 0 &&
+		!s.isCWOrRTTYMode(civOperatingModes[s.state.operatingModeIdx].code) {
+		filterCode = civFilters[cwRTTYFilterIdx].code
+	}
+	s.initCmd(&s.state.setMode, "setMode", prepPacket("setMode", []byte{modeCode, filterCode}))
+	if err := s.sendCmd(&s.state.setMode); err != nil {
+		return err
+	}
+	return s.getBothVFOMode()
+}
+
+// Sets the widths of FIL1-FIL3 for the current operating mode. The radio can only set the width of the
+// selected filter, so each filter is selected and its width is set, then the original filter is selected
+// again. The raw commands wait for the radio's answer, so this blocks until all filters are set.
+func (s *civControlStruct) setFilterWidths(widths []int) (err error) {
+	if len(widths) != len(civFilters) {
+		return fmt.Errorf("%d filter widths are needed", len(civFilters))
+	}
+
+	s.state.mutex.Lock()
+	modeIdx := s.state.operatingModeIdx
+	filterIdx := s.state.filterIdx
+	dataMode := s.state.dataMode
+	s.state.mutex.Unlock()
+
+	if modeIdx < 0 || !s.isSSBOrCWMode(civOperatingModes[modeIdx].code) {
+		return errors.New("filter widths can only be set in SSB and CW modes")
+	}
+	if filterIdx < 0 {
+		return errors.New("the selected filter is not known yet")
+	}
+	mode := civOperatingModes[modeIdx]
+	var encoded []byte
+	for _, w := range widths {
+		e, err := s.encodeIFFilterWidth(w)
+		if err != nil {
+			return err
+		}
+		encoded = append(encoded, e)
+	}
+
+	// Selecting the filter with the data mode command if it's on, as the mode command turns it off.
+	selectFilter := func(i int) error {
+		cmd := []byte{0x06, mode.code, civFilters[i].code}
+		if dataMode {
+			cmd = []byte{0x1a, 0x06, 0x01, civFilters[i].code}
+		}
+		d, err := s.sendRawCIV(cmd)
+		if err == nil && d[4] == NG {
+			err = errors.New("radio refused to select " + civFilters[i].name)
+		}
+		return err
+	}
+
+	// The original filter is selected again even if setting a width failed.
+	defer func() {
+		selectErr := selectFilter(filterIdx)
+		if err == nil {
+			err = selectErr
+		}
+		s.state.mutex.Lock()
+		defer s.state.mutex.Unlock()
+		s.state.filterWidthKey = "" // so the width of the selected filter is updated
+		if getErr := s.getBothVFOMode(); err == nil {
+			err = getErr
+		}
+	}()
+
+	for i := range civFilters {
+		if err := selectFilter(i); err != nil {
+			return err
+		}
+		d, err := s.sendRawCIV([]byte{0x1a, 0x03, encoded[i]})
+		if err != nil {
+			return err
+		}
+		if d[4] == NG {
+			return errors.New("radio refused to set the width of " + civFilters[i].name)
+		}
+		s.state.mutex.Lock()
+		civFilterWidths[mode.name+"/"+civFilters[i].name] = widths[i]
+		s.state.mutex.Unlock()
+		log.Print("set ", mode.name, " ", civFilters[i].name, " width to ", widths[i], "Hz")
+	}
+	return nil
+}
+
+func (s *civControlStruct) setSubVFOMode(modeCode, dataMode, filterCode byte) error {
+	s.initCmd(&s.state.setSubVFOMode, "setSubVFOMode", prepPacket("setSubVFOMode", []byte{modeCode, dataMode, filterCode}))
+	return s.sendCmd(&s.state.setSubVFOMode)
+}
+
+func (s *civControlStruct) setMainVFOMode(modeCode, dataMode, filterCode byte) error {
+	s.initCmd(&s.state.setMainVFOMode, "setMainVFOMode", prepPacket("setMainVFOMode", []byte{modeCode, dataMode, filterCode}))
+	return s.sendCmd(&s.state.setMainVFOMode)
+}
+
+// Copies VFO A's frequency and mode to VFO B, regardless of which VFO is active. The main VFO commands
+// work on the active VFO, and the sub VFO commands on the other one.
+func (s *civControlStruct) copyVFOAtoB() error {
+	var freq uint64
+	var modeIdx, filterIdx int
+	var dataMode bool
+	if s.state.vfoBActive {
+		freq, modeIdx, dataMode, filterIdx = s.state.subFreq, s.state.subOperatingModeIdx, s.state.subDataMode, s.state.subFilterIdx
+	} else {
+		freq, modeIdx, dataMode, filterIdx = s.state.freq, s.state.operatingModeIdx, s.state.dataMode, s.state.filterIdx
+	}
+	if freq == 0 || modeIdx < 0 || filterIdx < 0 {
+		return errors.New("VFO A is not known yet")
+	}
+	var dataModeByte byte
+	if dataMode {
+		dataModeByte = ON
+	}
+
+	log.Print("copying VFO A to B")
+	if s.state.vfoBActive {
+		if err := s.setMainVFOFreq(freq); err != nil {
+			return err
+		}
+		return s.setMainVFOMode(civOperatingModes[modeIdx].code, dataModeByte, civFilters[filterIdx].code)
+	}
+	if err := s.setSubVFOFreq(freq); err != nil {
+		return err
+	}
+	return s.setSubVFOMode(civOperatingModes[modeIdx].code, dataModeByte, civFilters[filterIdx].code)
+}
+
+// Tunes to the next time station in AM mode. Each call selects the next station from the list, so
+// repeated calls can be used to check propagation on several bands.
+func (s *civControlStruct) tuneToNextTimeStation() error {
+	if len(timeStations) == 0 {
+		return errors.New("no time stations set")
+	}
+	if s.state.timeStationIdx >= len(timeStations) {
+		s.state.timeStationIdx = 0
+	}
+	ts := timeStations[s.state.timeStationIdx]
+	s.state.timeStationIdx++
+
+	log.Print("tuning to ", ts.name, " on ", ts.freq)
+	if err := s.setMainVFOFreq(ts.freq); err != nil {
+		return err
+	}
+	for i := range civOperatingModes {
+		if civOperatingModes[i].name == "AM" {
+			if i == s.state.operatingModeIdx {
+				return nil
+			}
+			return s.setOperatingModeAndFilter(civOperatingModes[i].code, civFilters[s.state.filterIdx].code)
+		}
+	}
+	return nil
+}
+
+// jumps between the current and the last frequency and mode set by setMainVFOFreq()
+func (s *civControlStruct) swapLastFreq() error {
+	if s.state.lastFreq == 0 {
+		return errors.New("no last frequency")
+	}
+	freq, modeIdx, filterIdx := s.state.lastFreq, s.state.lastModeIdx, s.state.lastFilterIdx
+	if err := s.setMainVFOFreq(freq); err != nil {
+		return err
+	}
+	if modeIdx < 0 || (modeIdx == s.state.operatingModeIdx && filterIdx == s.state.filterIdx) {
+		return nil
+	}
+	return s.setOperatingModeAndFilter(civOperatingModes[modeIdx].code, civFilters[filterIdx].code)
+}
+
+// tunes to the home frequency and mode set by the --home option
+func (s *civControlStruct) goHome() error {
+	if homeFreq == 0 {
+		return errors.New("no home frequency set")
+	}
+	log.Print("tuning to home frequency ", homeFreq)
+	if err := s.setMainVFOFreq(homeFreq); err != nil {
+		return err
+	}
+	if homeModeIdx < 0 || homeModeIdx == s.state.operatingModeIdx {
+		return nil
+	}
+	return s.setOperatingModeAndFilter(civOperatingModes[homeModeIdx].code, civFilters[s.state.filterIdx].code)
+}
+
+// Returns false and the reason if transmitting is not allowed. All TX safety checks are done here, so
+// every way of keying the transceiver behaves the same.
+func (s *civControlStruct) canTransmit() (bool, string) {
+	return s.checkTX(false)
+}
+
+func (s *civControlStruct) checkTX(tune bool) (bool, string) {
+	if s.state.txInhibit {
+		return false, "TX inhibit is on"
+	}
+	if denyOutOfBandTX && !s.isInBand(s.state.freq) {
+		return false, "out of band"
+	}
+	if minTXVoltage > 0 && s.state.vd > 0 && s.state.vd < minTXVoltage {
+		return false, fmt.Sprintf("low voltage %.1fV", s.state.vd)
+	}
+	// Tuning is allowed with a high SWR, as tuning is what fixes it.
+	if !tune && maxTXSWR > 0 && s.state.swr > maxTXSWR {
+		return false, fmt.Sprintf("high SWR %.1f", s.state.swr)
+	}
+	return true, ""
+}
+
+// Releases PTT and aborts tuning when TX inhibit is turned on.
+func (s *civControlStruct) toggleTXInhibit() error {
+	s.state.txInhibit = !s.state.txInhibit
+	statusLog.reportTXInhibit(s.state.txInhibit)
+	if !s.state.txInhibit {
+		return nil
+	}
+	if s.state.tune {
+		return s.toggleAntennaTuner()
+	}
+	if s.state.ptt {
+		return s.setPTT(false)
+	}
+	return nil
+}
+
+func (s *civControlStruct) setPTT(enable bool) error {
+	var b byte
+	if enable {
+		if ok, reason := s.canTransmit(); !ok {
+			statusLog.reportTXDenied(reason)
+			return errors.New("tx denied: " + reason)
+		}
+		b = ON
+		// The displayed sub VFO frequency may lag behind during pileups, so it's read again. The answer
+		// arrives before the PTT answer, so the displayed TX frequency is the actual one while transmitting.
+		if splitTXCheck && s.state.splitMode == splitModeOn {
+			if err := s.getSubVFOFreq(); err != nil {
+				return err
+			}
+		}
+		s.state.pttTimeoutTimer = time.AfterFunc(pttTimeout, func() {
+			_ = s.setPTT(false)
+		})
+	}
+	s.initCmd(&s.state.setPTT, "setPTT", prepPacket("setPTT", []byte{b}))
+	return s.sendCmd(&s.state.setPTT)
+}
+
+// Releases PTT if needed, then powers off the radio and waits for the radio to confirm it.
+func (s *civControlStruct) powerOffRadio() error {
+	s.state.mutex.Lock()
+	transmitting := s.state.ptt || s.state.tune
+	var err error
+	if s.state.ptt {
+		log.Print("releasing ptt before powering off the radio")
+		err = s.setPTT(false)
+	} else if s.state.tune {
+		log.Print("aborting tuning before powering off the radio")
+		if s.state.tuneTimeoutTimer != nil {
+			s.state.tuneTimeoutTimer.Stop()
+			s.state.tuneTimeoutTimer = nil
+		}
+		err = s.setTunerEnabled(true)
+	}
+	s.state.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for start := time.Now(); transmitting; {
+		if time.Since(start) >= powerOffPTTReleaseTimeout {
+			return errors.New("can't stop transmitting")
+		}
+		time.Sleep(50 * time.Millisecond)
+		s.state.mutex.Lock()
+		transmitting = s.state.ptt || s.state.tune
+		s.state.mutex.Unlock()
+	}
+
+	log.Print("powering off the radio")
+	d, err := s.sendRawCIV([]byte{0x18, 0x00})
+	if err != nil {
+		return err
+	}
+	if d[4] != OK {
+		return errors.New("radio refused to power off")
+	}
+	log.Print("radio powered off")
+	return nil
+}
+
+func (s *civControlStruct) speak(name string) error {
+	s.initCmd(&s.state.speech, name, prepPacket(name, noData))
+	return s.sendCmd(&s.state.speech)
+}
+
+// make the radio announce the S meter level, the frequency and the operating mode with its voice synthesizer
+func (s *civControlStruct) announceAll() error {
+	return s.speak("speechAll")
+}
+
+// the radio has no separate speech commands for the frequency and the S meter level, so both are announced
+func (s *civControlStruct) announceFrequency() error {
+	return s.speak("speechFreqAndS")
+}
+
+func (s *civControlStruct) announceSLevel() error {
+	return s.speak("speechFreqAndS")
+}
+
+func (s *civControlStruct) announceMode() error {
+	return s.speak("speechMode")
+}
+
+// start transmitting the voice TX memory in the given slot (1-8)
+func (s *civControlStruct) playVoiceMemory(slot byte) error {
+	if slot < 1 || slot > 8 {
+		return fmt.Errorf("invalid voice memory slot %d", slot)
+	}
+	if s.state.ptt || s.state.tune {
+		return errors.New("already transmitting")
+	}
+	if ok, reason := s.canTransmit(); !ok {
+		statusLog.reportTXDenied(reason)
+		return errors.New("tx denied: " + reason)
+	}
+	log.Print("playing voice memory T", slot)
+	s.initCmd(&s.state.setVoiceTXMemory, "setVoiceTXMemory", prepPacket("setVoiceTXMemory", []byte{slot}))
+	return s.sendCmd(&s.state.setVoiceTXMemory)
+}
+
+// stop the voice TX memory playback
+func (s *civControlStruct) stopVoiceMemory() error {
+	s.initCmd(&s.state.setVoiceTXMemory, "setVoiceTXMemory", prepPacket("setVoiceTXMemory", []byte{0}))
+	return s.sendCmd(&s.state.setVoiceTXMemory)
+}
+
+// characters which can be sent with the sendCWMsg command
+func (s *civControlStruct) isValidCWMsgChar(c byte) bool {
+	switch {
+	case c >= '0' && c <= '9', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	}
+	return strings.IndexByte(" /?.-,:'()=+\"@^", c) >= 0
+}
+
+func (s *civControlStruct) sendCWMsgPart(part []byte) error {
+	s.initCmd(&s.state.sendCWMsg, "sendCWMsg", prepPacket("sendCWMsg", part))
+	return s.sendCmd(&s.state.sendCWMsg)
+}
+
+// sends the given text with the transceiver's keyer, characters which can't be sent are skipped
+func (s *civControlStruct) sendCWMsg(text string) error {
+	var msg []byte
+	for i := 0; i < len(text); i++ {
+		if s.isValidCWMsgChar(text[i]) {
+			msg = append(msg, text[i])
+		} else {
+			log.Debug("skipping cw message char ", string(text[i]))
+		}
+	}
+	if len(msg) == 0 {
+		return errors.New("nothing to send")
+	}
+
+	for len(msg) > 0 {
+		l := len(msg)
+		if l > maxCWMsgLength {
+			l = maxCWMsgLength
+		}
+		s.state.cwMsgQueue = append(s.state.cwMsgQueue, string(msg[:l]))
+		msg = msg[l:]
+	}
+	if s.state.sendCWMsg.pending {
+		return nil
+	}
+	part := s.state.cwMsgQueue[0]
+	s.state.cwMsgQueue = s.state.cwMsgQueue[1:]
+	return s.sendCWMsgPart([]byte(part))
+}
+
+func (s *civControlStruct) stopCWMsg() error {
+	s.state.cwMsgQueue = nil
+	s.state.keyerMemoryToSend = 0
+	return s.sendCWMsgPart([]byte{0xff})
+}
+
+func (s *civControlStruct) getKeyerMemory(slot byte) error {
+	s.initCmd(&s.state.getKeyerMemory, "getKeyerMemory", prepPacket("getKeyerMemory", []byte{slot}))
+	return s.sendCmd(&s.state.getKeyerMemory)
+}
+
+// The transceiver has no command for sending a memory keyer slot, so we read the contents of the slot
+// and send them as a CW message when they arrive.
+func (s *civControlStruct) sendKeyerMemory(slot byte) error {
+	if slot < 1 || slot > 8 {
+		return fmt.Errorf("invalid keyer memory slot %d", slot)
 	}
-	return s.getBothVFOMode()
+	if s.state.operatingModeIdx < 0 || !s.isCWMode(civOperatingModes[s.state.operatingModeIdx].code) {
+		return errors.New("not in CW mode")
+	}
+	if ok, reason := s.canTransmit(); !ok {
+		statusLog.reportTXDenied(reason)
+		return errors.New("tx denied: " + reason)
+	}
+	s.state.keyerMemoryToSend = slot
+	return s.getKeyerMemory(slot)
 }
 
-func (s *civControlStruct) setSubVFOMode(modeCode, dataMode, filterCode byte) error {
-	s.initCmd(&s.state.setSubVFOMode, "setSubVFOMode", prepPacket("setSubVFOMode", []byte{modeCode, dataMode, filterCode}))
-	return s.sendCmd(&s.state.setSubVFOMode)
+func (s *civControlStruct) setKeyerMemory(slot byte, text string) error {
+	if slot < 1 || slot > 8 {
+		return fmt.Errorf("invalid keyer memory slot %d", slot)
+	}
+	if len(text) > maxKeyerMemoryLength {
+		return fmt.Errorf("keyer memory text is longer than %d chars", maxKeyerMemoryLength)
+	}
+	for i := 0; i < len(text); i++ {
+		// * is the contest number in keyer memories
+		if !s.isValidCWMsgChar(text[i]) && text[i] != '*' {
+			return fmt.Errorf("invalid char %s in keyer memory text", string(text[i]))
+		}
+	}
+	s.initCmd(&s.state.setKeyerMemory, "setKeyerMemory", prepPacket("setKeyerMemory", append([]byte{slot}, text...)))
+	return s.sendCmd(&s.state.setKeyerMemory)
 }
 
-// TODO: add controls to prevent pushing PTT if outside licensed allocations
-func (s *civControlStruct) setPTT(enable bool) error {
-	var b byte
+// put the antenna tuner in-line or bypass it, setting it in-line while tuning aborts the tuning
+func (s *civControlStruct) setTunerEnabled(enable bool) error {
+	var b byte // per CI-V guide: 0=off, 1=on, 2=tune
 	if enable {
 		b = ON
-		s.state.pttTimeoutTimer = time.AfterFunc(pttTimeout, func() {
-			_ = s.setPTT(false)
-		})
 	}
-	s.initCmd(&s.state.setPTT, "setPTT", prepPacket("setPTT", []byte{b}))
-	return s.sendCmd(&s.state.setPTT)
+	s.initCmd(&s.state.setTunerEnabled, "setTunerEnabled", prepPacket("setTunerEnabled", []byte{b}))
+	return s.sendCmd(&s.state.setTunerEnabled)
 }
 
-// enable/disable antenna tuner
-func (s *civControlStruct) setTune(enable bool) error {
+func (s *civControlStruct) toggleTunerEnabled() error {
+	return s.setTunerEnabled(!s.state.tunerEnabled)
+}
+
+// start the antenna tuning process, the tuner stays in-line after it's finished
+func (s *civControlStruct) triggerTune() error {
 	if s.state.ptt {
 		return nil
 	}
+	if ok, reason := s.checkTX(true); !ok {
+		statusLog.reportTXDenied(reason)
+		return errors.New("tx denied: " + reason)
+	}
 
-	var b byte // per CI-V guide: 0=off, 1=on, 2=tune
-	if enable {
-		b = 2
+	if maxTunePwrLevel > 0 && s.state.pwrLevel > maxTunePwrLevel {
+		s.state.pwrLevelBeforeTune = s.state.pwrLevel
+		s.state.restorePwrAfterTune = true
+		if err := s.setPwr(maxTunePwrLevel); err != nil {
+			s.state.restorePwrAfterTune = false
+			return err
+		}
+	}
+
+	if tuneTimeout > 0 {
 		s.state.tuneTimeoutTimer = time.AfterFunc(tuneTimeout, func() {
 			s.state.tuneTimeoutTimer = nil
-			_ = s.setTune(false)
+			log.Print("tuning timed out after ", tuneTimeout, ", aborting")
+			_ = s.setTunerEnabled(true)
+			s.restorePwrAfterTuneIfNeeded()
 		})
-	} else {
-		// BUG? this was value in codebase, but shouldn't it be to OFF  (and we only see ON when asking about it's state?)
-		// actual behavior appears to be the same even it is set to OFF here
-		b = ON
+		statusLog.reportTuneDeadline(time.Now().Add(tuneTimeout))
 	}
-	s.initCmd(&s.state.setTune, "setTune", prepPacket("setTune", []byte{b}))
+	s.initCmd(&s.state.setTune, "setTune", prepPacket("setTune", []byte{2}))
 	return s.sendCmd(&s.state.setTune)
 }
 
+// set back the TX power which was lowered by triggerTune()
+func (s *civControlStruct) restorePwrAfterTuneIfNeeded() {
+	if !s.state.restorePwrAfterTune {
+		return
+	}
+	if err := s.setPwr(s.state.pwrLevelBeforeTune); err != nil {
+		log.Error("can't restore tx power after tuning: ", err)
+		return
+	}
+	s.state.restorePwrAfterTune = false
+}
+
+// start tuning, or abort it if it's already in progress
 func (s *civControlStruct) toggleAntennaTuner() error {
-	return s.setTune(!s.state.tune)
+	if s.state.tune {
+		if s.state.tuneTimeoutTimer != nil {
+			s.state.tuneTimeoutTimer.Stop()
+			s.state.tuneTimeoutTimer = nil
+		}
+		return s.setTunerEnabled(true)
+	}
+	return s.triggerTune()
 }
 
 func (s *civControlStruct) setDataMode(enable bool) error {
@@ -1353,7 +3454,10 @@ func (s *civControlStruct) incBand() error {
 	if i >= len(civBands) {
 		i = 0
 	}
-	f := civBands[i].freq
+	f := civBands[i].entryFreq
+	if f == 0 {
+		f = civBands[i].freq
+	}
 	if f == 0 {
 		f = (civBands[i].freqFrom + civBands[i].freqTo) / 2
 	}
@@ -1365,7 +3469,10 @@ func (s *civControlStruct) decBand() error {
 	if i < 0 {
 		i = len(civBands) - 1
 	}
-	f := civBands[i].freq
+	f := civBands[i].entryFreq
+	if f == 0 {
+		f = civBands[i].freq
+	}
 	if f == 0 {
 		f = civBands[i].freqFrom
 	}
@@ -1375,8 +3482,12 @@ func (s *civControlStruct) decBand() error {
 // NOTE: better name might be rotatePreamp
 func (s *civControlStruct) togglePreamp() error {
 	// NOTE: in HF there is PAMP1 & PAMP2, in VHF just "on" (same as PAMP1)
+	maxPreamp := byte(2)
+	if !s.isTwoStagePreamp() {
+		maxPreamp = 1
+	}
 	b := byte(s.state.preamp + 1)
-	if b > 2 {
+	if b > maxPreamp {
 		b = OFF
 	}
 	s.initCmd(&s.state.setPreamp, "setPreamp", prepPacket("setPreamp", []byte{b}))
@@ -1403,6 +3514,15 @@ func (s *civControlStruct) toggleNR() error {
 	return s.sendCmd(&s.state.setNREnabled)
 }
 
+func (s *civControlStruct) toggleNB() error {
+	var b byte
+	if !s.state.nbEnabled {
+		b = ON
+	}
+	s.initCmd(&s.state.setNBEnabled, "setNBEnabled", prepPacket("setNBEnabled", []byte{b}))
+	return s.sendCmd(&s.state.setNBEnabled)
+}
+
 func (s *civControlStruct) setTuningStep(b byte) error {
 	// NOTE: only values 00 - 13 are valid  (enforced in the (inc|dec)TuningStep functions)
 	//       we may want to enforce here if adding a direct selection method to the codebase
@@ -1438,6 +3558,32 @@ func (s *civControlStruct) setVFO(nr byte) error {
 	return s.getBothVFOMode()
 }
 
+func (s *civControlStruct) setVFOMode() error {
+	s.initCmd(&s.state.setVFOMode, "setVFO", prepPacket("setVFO", noData))
+	return s.sendCmd(&s.state.setVFOMode)
+}
+
+func (s *civControlStruct) setMemoryMode() error {
+	s.initCmd(&s.state.setMemoryMode, "setMemoryMode", prepPacket("setMemoryMode", noData))
+	return s.sendCmd(&s.state.setMemoryMode)
+}
+
+// selects the given memory channel, this also switches to memory mode
+func (s *civControlStruct) setMemoryChannel(ch int) error {
+	if ch < 0 || ch > maxMemoryChannel {
+		return fmt.Errorf("invalid memory channel %d", ch)
+	}
+	s.initCmd(&s.state.setMemoryChannel, "setMemoryChannel", prepPacket("setMemoryChannel", s.encodeLevelData(ch)))
+	return s.sendCmd(&s.state.setMemoryChannel)
+}
+
+// Channels are read from the first memory group, as the group can't be queried.
+func (s *civControlStruct) getMemoryName(ch int) error {
+	s.initCmd(&s.state.getMemoryName, "getMemoryName", prepPacket("getMemoryName",
+		append([]byte{0x00}, s.encodeLevelData(ch)...)))
+	return s.sendCmd(&s.state.getMemoryName)
+}
+
 func (s *civControlStruct) toggleVFO() error {
 	// NOTE: I believe we could also use the exchangeVFO command, and make sure we update s.state to reflect which is active:
 	var b byte
@@ -1481,6 +3627,173 @@ func (s *civControlStruct) toggleSplit() error {
 	return s.setSplit(mode)
 }
 
+func (s *civControlStruct) setRITEnabled(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setRITEnabled, "setRITEnabled", prepPacket("setRITEnabled", []byte{b}))
+	return s.sendCmd(&s.state.setRITEnabled)
+}
+
+func (s *civControlStruct) toggleRIT() error {
+	return s.setRITEnabled(!s.state.ritEnabled)
+}
+
+// Turns RIT off and zeros the offset, like the radio's CLEAR button. As RIT and XIT share the offset,
+// this zeros the XIT offset too.
+func (s *civControlStruct) clearRIT() error {
+	if err := s.setRITEnabled(false); err != nil {
+		return err
+	}
+	return s.setXIT(0)
+}
+
+// Changes the RIT offset by the given Hz, RIT is turned on if it's off.
+func (s *civControlStruct) nudgeRIT(deltaHz int) error {
+	offset := s.state.xitOffset + deltaHz
+	if offset < -9999 {
+		offset = -9999
+	} else if offset > 9999 {
+		offset = 9999
+	}
+	if !s.state.ritEnabled {
+		if err := s.setRITEnabled(true); err != nil {
+			return err
+		}
+	}
+	return s.setXIT(offset)
+}
+
+// lock the radio's front panel dial, so the frequency can't be changed accidentally
+func (s *civControlStruct) setDialLock(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setDialLock, "setDialLock", prepPacket("setDialLock", []byte{b}))
+	return s.sendCmd(&s.state.setDialLock)
+}
+
+func (s *civControlStruct) toggleDialLock() error {
+	return s.setDialLock(!s.state.dialLock)
+}
+
+// with transceive on, the radio sends frequency and mode changes without being asked
+func (s *civControlStruct) setTransceive(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setTransceive, "setTransceive", prepPacket("setTransceive", []byte{b}))
+	return s.sendCmd(&s.state.setTransceive)
+}
+
+// OVF polling can be turned off to reduce the CI-V traffic. The OVF state is refreshed right away when
+// it's turned back on.
+// Sets the TX delay of the current band group to the given civTXDelays idx. The band group's menu item
+// is checked against the main VFO frequency, so the delay of another band group is not overwritten if
+// the band changed since the TX delay was read.
+func (s *civControlStruct) setTXDelay(idx int) error {
+	if idx < 0 || idx >= len(civTXDelays) {
+		return fmt.Errorf("invalid tx delay idx %d", idx)
+	}
+	if s.getTXDelayGroupIdx() != s.state.txDelayGroupIdx {
+		return errors.New("tx delay of the current band group is not known yet")
+	}
+	item := civTXDelayGroups[s.state.txDelayGroupIdx].item
+	s.initCmd(&s.state.setTXDelay, "setTXDelay", prepPacket("setTXDelay", append(append([]byte{}, item...), byte(idx))))
+	if err := s.sendCmd(&s.state.setTXDelay); err != nil {
+		return err
+	}
+	return s.getTXDelay()
+}
+
+func (s *civControlStruct) cycleTXDelay() error {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	return s.setTXDelay((s.state.txDelay + 1) % len(civTXDelays))
+}
+
+func (s *civControlStruct) toggleOVFPolling() error {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	s.state.ovfPollingOff = !s.state.ovfPollingOff
+	statusLog.reportOVFStale(s.state.ovfPollingOff)
+	if s.state.ovfPollingOff {
+		log.Print("OVF polling is off")
+		return nil
+	}
+	log.Print("OVF polling is on")
+	return s.getOVF()
+}
+
+func (s *civControlStruct) toggleTransceive() error {
+	return s.setTransceive(!s.state.transceive)
+}
+
+// dual watch receives the main and the sub VFO frequencies on the same band simultaneously
+func (s *civControlStruct) setDualWatch(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setDualWatch, "setDualWatch", prepPacket("setDualWatch", []byte{b}))
+	return s.sendCmd(&s.state.setDualWatch)
+}
+
+func (s *civControlStruct) toggleAttenuator() error {
+	var b byte
+	if s.state.attenuator == 0 {
+		b = 0x20 // the IC-705 only has a 20dB attenuator
+	}
+	s.initCmd(&s.state.setAttenuator, "setAttenuator", prepPacket("setAttenuator", []byte{b}))
+	return s.sendCmd(&s.state.setAttenuator)
+}
+
+func (s *civControlStruct) toggleDualWatch() error {
+	return s.setDualWatch(!s.state.dualWatch)
+}
+
+// holding freezes the scope display, so transient signals can be examined
+func (s *civControlStruct) setScopeHold(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setScopeHold, "setScopeHold", prepPacket("setScopeHold", []byte{b}))
+	return s.sendCmd(&s.state.setScopeHold)
+}
+
+func (s *civControlStruct) toggleScopeHold() error {
+	return s.setScopeHold(!s.state.scope.hold)
+}
+
+func (s *civControlStruct) setXITEnabled(enable bool) error {
+	var b byte
+	if enable {
+		b = ON
+	}
+	s.initCmd(&s.state.setXITEnabled, "setXITEnabled", prepPacket("setXITEnabled", []byte{b}))
+	return s.sendCmd(&s.state.setXITEnabled)
+}
+
+func (s *civControlStruct) toggleXIT() error {
+	return s.setXITEnabled(!s.state.xitEnabled)
+}
+
+// sets the XIT offset in Hz, valid range is -9999 to 9999
+func (s *civControlStruct) setXIT(offsetHz int) error {
+	if offsetHz < -9999 || offsetHz > 9999 {
+		return fmt.Errorf("xit offset %d out of range", offsetHz)
+	}
+	b := s.encodeOffsetData(offsetHz)
+	s.initCmd(&s.state.setXIT, "setXIT", prepPacket("setXIT", b[:]))
+	return s.sendCmd(&s.state.setXIT)
+}
+
 func (s *civControlStruct) getFreq() error {
 	s.initCmd(&s.state.getFreq, "getFreq", prepPacket("getFreq", noData))
 	return s.sendCmd(&s.state.getFreq)
@@ -1505,6 +3818,11 @@ func (s *civControlStruct) getPreamp() error {
 	return s.sendCmd(&s.state.getPreamp)
 }
 
+func (s *civControlStruct) getAttenuator() error {
+	s.initCmd(&s.state.getAttenuator, "getAttenuator", prepPacket("getAttenuator", noData))
+	return s.sendCmd(&s.state.getAttenuator)
+}
+
 func (s *civControlStruct) getAGC() error {
 	s.initCmd(&s.state.getAGC, "getAGC", prepPacket("getAGC", noData))
 	return s.sendCmd(&s.state.getAGC)
@@ -1520,6 +3838,11 @@ func (s *civControlStruct) getS() error {
 	return s.sendCmd(&s.state.getS)
 }
 
+func (s *civControlStruct) getSquelchStatus() error {
+	s.initCmd(&s.state.getSquelchStatus, "getSquelchStatus", prepPacket("getSquelchStatus", noData))
+	return s.sendCmd(&s.state.getSquelchStatus)
+}
+
 func (s *civControlStruct) getOVF() error {
 	s.initCmd(&s.state.getOVF, "getOVF", prepPacket("getOVF", noData))
 	return s.sendCmd(&s.state.getOVF)
@@ -1530,6 +3853,16 @@ func (s *civControlStruct) getSWR() error {
 	return s.sendCmd(&s.state.getSWR)
 }
 
+func (s *civControlStruct) getALC() error {
+	s.initCmd(&s.state.getALC, "getALC", prepPacket("getALC", noData))
+	return s.sendCmd(&s.state.getALC)
+}
+
+func (s *civControlStruct) getComp() error {
+	s.initCmd(&s.state.getComp, "getComp", prepPacket("getComp", noData))
+	return s.sendCmd(&s.state.getComp)
+}
+
 func (s *civControlStruct) getTuningStep() error {
 	s.initCmd(&s.state.getTuningStep, "getTuningStep", prepPacket("getTuningStep", noData))
 	return s.sendCmd(&s.state.getTuningStep)
@@ -1550,6 +3883,25 @@ func (s *civControlStruct) getNR() error {
 	return s.sendCmd(&s.state.getNR)
 }
 
+func (s *civControlStruct) getNB() error {
+	s.initCmd(&s.state.getNB, "getNB", prepPacket("getNB", noData))
+	return s.sendCmd(&s.state.getNB)
+}
+
+func (s *civControlStruct) getNBEnabled() error {
+	s.initCmd(&s.state.getNBEnabled, "getNBEnabled", prepPacket("getNBEnabled", noData))
+	return s.sendCmd(&s.state.getNBEnabled)
+}
+
+func (s *civControlStruct) getNBDepthWidth() error {
+	s.initCmd(&s.state.getNBDepth, "getNBDepth", prepPacket("getNBDepth", noData))
+	if err := s.sendCmd(&s.state.getNBDepth); err != nil {
+		return err
+	}
+	s.initCmd(&s.state.getNBWidth, "getNBWidth", prepPacket("getNBWidth", noData))
+	return s.sendCmd(&s.state.getNBWidth)
+}
+
 func (s *civControlStruct) getNREnabled() error {
 	s.initCmd(&s.state.getNREnabled, "getNREnabled", prepPacket("getNREnabled", noData))
 	return s.sendCmd(&s.state.getNREnabled)
@@ -1560,11 +3912,94 @@ func (s *civControlStruct) getSplit() error {
 	return s.sendCmd(&s.state.getSplit)
 }
 
+func (s *civControlStruct) getDuplexOffset() error {
+	s.initCmd(&s.state.getDuplexOffset, "getDuplexOffset", prepPacket("getDuplexOffset", noData))
+	return s.sendCmd(&s.state.getDuplexOffset)
+}
+
+func (s *civControlStruct) getPowerSource() error {
+	s.state.lastPowerSourceAt = time.Now()
+	s.initCmd(&s.state.getPowerSource, "getPowerSource", prepPacket("getPowerSource", noData))
+	return s.sendCmd(&s.state.getPowerSource)
+}
+
+func (s *civControlStruct) getPassband() error {
+	s.state.lastPassbandPolledAt = time.Now()
+	s.initCmd(&s.state.getPBT1, "getPBT1", prepPacket("getPBT1", noData))
+	if err := s.sendCmd(&s.state.getPBT1); err != nil {
+		return err
+	}
+	s.initCmd(&s.state.getPBT2, "getPBT2", prepPacket("getPBT2", noData))
+	if err := s.sendCmd(&s.state.getPBT2); err != nil {
+		return err
+	}
+	if err := s.getIFFilterWidth(); err != nil {
+		return err
+	}
+	s.initCmd(&s.state.getCWPitch, "getCWPitch", prepPacket("getCWPitch", noData))
+	return s.sendCmd(&s.state.getCWPitch)
+}
+
+func (s *civControlStruct) getIFFilterWidth() error {
+	s.initCmd(&s.state.getIFFilterWidth, "getIFFilterWidth", prepPacket("getIFFilterWidth", noData))
+	return s.sendCmd(&s.state.getIFFilterWidth)
+}
+
+func (s *civControlStruct) getScopeState() error {
+	s.state.lastScopeStatePolledAt = time.Now()
+	s.initCmd(&s.state.getScopeOn, "getScopeOn", prepPacket("getScopeOn", noData))
+	if err := s.sendCmd(&s.state.getScopeOn); err != nil {
+		return err
+	}
+	s.initCmd(&s.state.getScopeHold, "getScopeHold", prepPacket("getScopeHold", noData))
+	return s.sendCmd(&s.state.getScopeHold)
+}
+
+func (s *civControlStruct) getTransceive() error {
+	s.initCmd(&s.state.getTransceive, "getTransceive", prepPacket("getTransceive", noData))
+	return s.sendCmd(&s.state.getTransceive)
+}
+
+func (s *civControlStruct) getTXDelay() error {
+	item := civTXDelayGroups[s.state.txDelayGroupIdx].item
+	s.initCmd(&s.state.getTXDelay, "getTXDelay", prepPacket("getTXDelay", item))
+	return s.sendCmd(&s.state.getTXDelay)
+}
+
+func (s *civControlStruct) getDialLock() error {
+	s.initCmd(&s.state.getDialLock, "getDialLock", prepPacket("getDialLock", noData))
+	return s.sendCmd(&s.state.getDialLock)
+}
+
+func (s *civControlStruct) getDualWatch() error {
+	s.initCmd(&s.state.getDualWatch, "getDualWatch", prepPacket("getDualWatch", noData))
+	return s.sendCmd(&s.state.getDualWatch)
+}
+
+func (s *civControlStruct) getRITEnabled() error {
+	s.initCmd(&s.state.getRITEnabled, "getRITEnabled", prepPacket("getRITEnabled", noData))
+	return s.sendCmd(&s.state.getRITEnabled)
+}
+
+func (s *civControlStruct) getXITEnabled() error {
+	s.initCmd(&s.state.getXITEnabled, "getXITEnabled", prepPacket("getXITEnabled", noData))
+	return s.sendCmd(&s.state.getXITEnabled)
+}
+
+func (s *civControlStruct) getXIT() error {
+	s.initCmd(&s.state.getXIT, "getXIT", prepPacket("getXIT", noData))
+	return s.sendCmd(&s.state.getXIT)
+}
+
 func (s *civControlStruct) getBothVFOFreq() error {
 	s.initCmd(&s.state.getMainVFOFreq, "getMainVFOFreq", prepPacket("getMainVFOFreq", noData))
 	if err := s.sendCmd(&s.state.getMainVFOFreq); err != nil {
 		return err
 	}
+	return s.getSubVFOFreq()
+}
+
+func (s *civControlStruct) getSubVFOFreq() error {
 	s.initCmd(&s.state.getSubVFOFreq, "getSubVFOFreq", prepPacket("getSubVFOFreq", noData))
 	return s.sendCmd(&s.state.getSubVFOFreq)
 }
@@ -1583,6 +4018,9 @@ func (s *civControlStruct) loop() {
 		s.state.mutex.Lock()
 		nextPendingCmdTimeout := time.Hour
 		for i := range s.state.pendingCmds {
+			if s.state.pendingCmds[i].queued {
+				continue
+			}
 			diff := time.Since(s.state.pendingCmds[i].sentAt)
 			if diff >= commandRetryTimeout {
 				nextPendingCmdTimeout = 0
@@ -1592,37 +4030,111 @@ func (s *civControlStruct) loop() {
 				nextPendingCmdTimeout = diff
 			}
 		}
+		meterPollInterval := statusPollInterval
+		if idlePollAfter > 0 {
+			idle := time.Since(s.state.lastUserActionAt) >= idlePollAfter
+			if idle != s.state.idle {
+				s.state.idle = idle
+				if idle {
+					log.Print("no user action for ", idlePollAfter, ", polling meters every ", idlePollInterval)
+				} else {
+					log.Print("user action, polling meters at full rate")
+				}
+			}
+			if idle {
+				meterPollInterval = idlePollInterval
+			}
+		}
+		pollInterval := statusPollInterval
+		if s.state.splitMode == splitModeOn {
+			pollInterval = splitSubVFOFreqPollInterval
+		}
+		monitorSquelchPoll := monitorSquelch && s.isSQLSensitivityMode()
+		if monitorSquelchPoll {
+			pollInterval = squelchStatusPollInterval
+		} else {
+			// In the other modes the squelch doesn't gate the audio, so the monitor is not muted.
+			s.state.squelchClosed = false
+		}
 		s.state.mutex.Unlock()
 
 		select {
 		case <-s.deinitNeeded:
 			s.deinitFinished <- true
 			return
-		case <-time.After(statusPollInterval):
+		case <-time.After(pollInterval):
 			if s.state.ptt || s.state.tune {
 				if !s.state.getSWR.pending && time.Since(s.state.lastSWRReceivedAt) >= statusPollInterval {
 					_ = s.getSWR()
 				}
+				if s.state.ptt { // The ALC and compressor meters are not used while tuning.
+					if !s.state.getALC.pending && time.Since(s.state.lastALCReceivedAt) >= statusPollInterval {
+						_ = s.getALC()
+					}
+					if !s.state.getComp.pending && time.Since(s.state.lastCompReceivedAt) >= statusPollInterval {
+						_ = s.getComp()
+					}
+				}
 			} else {
-				if !s.state.getS.pending && time.Since(s.state.lastSReceivedAt) >= statusPollInterval {
+				if !s.state.getS.pending && time.Since(s.state.lastSReceivedAt) >= meterPollInterval {
 					_ = s.getS()
 				}
-				if !s.state.getOVF.pending && time.Since(s.state.lastOVFReceivedAt) >= statusPollInterval {
+				if !s.state.ovfPollingOff && !s.state.getOVF.pending &&
+					time.Since(s.state.lastOVFReceivedAt) >= meterPollInterval {
 					_ = s.getOVF()
 				}
+				statusLog.reportOVFStale(s.state.ovfPollingOff ||
+					(s.state.getOVF.pending && time.Since(s.state.getOVF.sentAt) >= commandRetryTimeout))
+				if monitorSquelchPoll && !s.state.getSquelchStatus.pending &&
+					time.Since(s.state.lastSquelchStatusAt) >= squelchStatusPollInterval {
+					_ = s.getSquelchStatus()
+				}
+			}
+			vfoFreqPollInterval := statusPollInterval
+			if s.state.transceive {
+				vfoFreqPollInterval = transceiveVFOFreqPollInterval
 			}
 			if !s.state.getMainVFOFreq.pending && !s.state.getSubVFOFreq.pending &&
-				time.Since(s.state.lastVFOFreqReceivedAt) >= statusPollInterval {
+				time.Since(s.state.lastVFOFreqPolledAt) >= vfoFreqPollInterval {
+				s.state.lastVFOFreqPolledAt = time.Now()
 				_ = s.getBothVFOFreq()
+			} else if s.state.splitMode == splitModeOn && !s.state.getSubVFOFreq.pending &&
+				time.Since(s.state.lastSubVFOFreqReceivedAt) >= splitSubVFOFreqPollInterval {
+				_ = s.getSubVFOFreq()
+			}
+			// the power source can change any time, but it's not sent by the radio
+			if !s.state.getPowerSource.pending && time.Since(s.state.lastPowerSourceAt) >= powerSourcePollInterval {
+				_ = s.getPowerSource()
 			}
+			// The passband and scope state are also written by decode(), so these polls are done under the
+			// state mutex.
+			s.state.mutex.Lock()
+			if s.state.operatingModeIdx >= 0 && s.isSSBOrCWMode(civOperatingModes[s.state.operatingModeIdx].code) {
+				if !s.state.getPBT1.pending && !s.state.getPBT2.pending &&
+					time.Since(s.state.lastPassbandPolledAt) >= passbandPollInterval {
+					_ = s.getPassband()
+				}
+			}
+			s.updatePassband()
+			// the scope state can be changed on the radio's touch screen, and it's not sent by the radio
+			if !s.state.getScopeOn.pending && !s.state.getScopeHold.pending &&
+				time.Since(s.state.lastScopeStatePolledAt) >= scopeStatePollInterval {
+				_ = s.getScopeState()
+			}
+			s.state.mutex.Unlock()
 		case <-s.resetSReadTimer:
 		case <-s.newPendingCmdAdded:
 		case <-time.After(nextPendingCmdTimeout):
 			s.state.mutex.Lock()
 			for _, cmd := range s.state.pendingCmds {
-				if time.Since(cmd.sentAt) >= commandRetryTimeout {
+				if !cmd.queued && time.Since(cmd.sentAt) >= commandRetryTimeout {
 					log.Debug("retrying cmd send ", cmd.name)
-					_ = s.sendCmd(cmd)
+					cmd.retried = true
+					// If the serial stream is dead, then retrying is pointless, so we trigger a reconnect.
+					if err := s.sendCmd(cmd); err != nil {
+						reportError(fmt.Errorf("can't send cmd %s: %w", cmd.name, err))
+						break
+					}
 				}
 			}
 			s.state.mutex.Unlock()
@@ -1630,8 +4142,26 @@ func (s *civControlStruct) loop() {
 	}
 }
 
+// Called on user actions (like keypresses), so meters are polled at full rate again if they were polled
+// less often because of idling.
+func (s *civControlStruct) reportUserAction() {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	s.state.lastUserActionAt = time.Now()
+}
+
 func (s *civControlStruct) init(st *serialStream) error {
 	s.st = st
+	if civCmdGap > 0 {
+		s.sendQueue = make(chan *civCmd, civSendQueueLen)
+		s.sendLoopDeinitNeeded = make(chan bool)
+		s.sendLoopDeinitFinished = make(chan bool)
+		go s.sendLoop(st)
+	}
+	s.state.lastUserActionAt = time.Now()
+	s.state.memoryChannel = -1
+	s.state.twoStagePreamp = true
 
 	if err := s.getFreq(); err != nil {
 		return err
@@ -1651,6 +4181,9 @@ func (s *civControlStruct) init(st *serialStream) error {
 	if err := s.getPreamp(); err != nil {
 		return err
 	}
+	if err := s.getAttenuator(); err != nil {
+		return err
+	}
 	if err := s.getAGC(); err != nil {
 		return err
 	}
@@ -1681,9 +4214,48 @@ func (s *civControlStruct) init(st *serialStream) error {
 	if err := s.getNREnabled(); err != nil {
 		return err
 	}
+	if err := s.getNB(); err != nil {
+		return err
+	}
+	if err := s.getNBEnabled(); err != nil {
+		return err
+	}
+	if err := s.getNBDepthWidth(); err != nil {
+		return err
+	}
 	if err := s.getSplit(); err != nil {
 		return err
 	}
+	if err := s.getRITEnabled(); err != nil {
+		return err
+	}
+	if err := s.getXITEnabled(); err != nil {
+		return err
+	}
+	if err := s.getXIT(); err != nil {
+		return err
+	}
+	if err := s.getDualWatch(); err != nil {
+		return err
+	}
+	if err := s.getDialLock(); err != nil {
+		return err
+	}
+	if err := s.getPowerSource(); err != nil {
+		return err
+	}
+	if err := s.getTransceive(); err != nil {
+		return err
+	}
+	if err := s.getTXDelay(); err != nil {
+		return err
+	}
+	if err := s.getPassband(); err != nil {
+		return err
+	}
+	if err := s.getScopeState(); err != nil {
+		return err
+	}
 
 	s.deinitNeeded = make(chan bool)
 	s.deinitFinished = make(chan bool)
@@ -1693,7 +4265,31 @@ func (s *civControlStruct) init(st *serialStream) error {
 	return nil
 }
 
+// Clears the CI-V control for a new connection or transceiver. The struct is not overwritten, as other
+// goroutines may be using its state mutex meanwhile.
+func (s *civControlStruct) reset() {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+
+	s.st = nil
+	s.deinitNeeded = nil
+	s.deinitFinished = nil
+	s.resetSReadTimer = nil
+	s.newPendingCmdAdded = nil
+	s.sendQueue = nil
+	s.sendLoopDeinitNeeded = nil
+	s.sendLoopDeinitFinished = nil
+	s.state.civControlState = civControlState{}
+}
+
 func (s *civControlStruct) deinit() {
+	if s.sendLoopDeinitNeeded != nil {
+		s.sendLoopDeinitNeeded <- true
+		<-s.sendLoopDeinitFinished
+		s.sendLoopDeinitNeeded = nil
+		s.sendQueue = nil
+	}
+
 	if s.deinitNeeded == nil {
 		return
 	}
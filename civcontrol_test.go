@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrepPacket(t *testing.T) {
+	origCIVAddress, origControllerAddress := civAddress, controllerAddress
+	defer func() {
+		civAddress, controllerAddress = origCIVAddress, origControllerAddress
+	}()
+	civAddress = 0xa4
+	controllerAddress = 0xe0
+
+	tests := []struct {
+		command string
+		data    []byte
+		want    []byte
+	}{
+		{"getFreq", nil, []byte{0xfe, 0xfe, 0xa4, 0xe0, 0x03, 0xfd}},
+		{"setMainVFOFreq", []byte{0x00, 0x40, 0x07, 0x07, 0x00},
+			[]byte{0xfe, 0xfe, 0xa4, 0xe0, 0x25, 0x00, 0x00, 0x40, 0x07, 0x07, 0x00, 0xfd}},
+		{"getScopeHold", nil, []byte{0xfe, 0xfe, 0xa4, 0xe0, 0x27, 0x17, 0x00, 0xfd}},
+	}
+	for _, test := range tests {
+		if got := prepPacket(test.command, test.data); !bytes.Equal(got, test.want) {
+			t.Errorf("%s: got % x, want % x", test.command, got, test.want)
+		}
+	}
+}
+
+// The radio's address comes first, so the radio knows the frame is for it, then ours as the sender.
+func TestPrepPacketAddresses(t *testing.T) {
+	origCIVAddress, origControllerAddress := civAddress, controllerAddress
+	defer func() {
+		civAddress, controllerAddress = origCIVAddress, origControllerAddress
+	}()
+	civAddress = 0x94
+	controllerAddress = 0xe1
+
+	pkt := prepPacket("getFreq", nil)
+	if pkt[2] != 0x94 || pkt[3] != 0xe1 {
+		t.Errorf("got addresses %02x %02x, want 94 e1", pkt[2], pkt[3])
+	}
+}
+
+// Sets up the addresses, the logging and a clean CI-V control for decode tests. Everything is restored
+// when the test finishes.
+func setupCIVTest(t *testing.T) *civControlStruct {
+	origCIVAddress, origControllerAddress := civAddress, controllerAddress
+	t.Cleanup(func() {
+		civAddress, controllerAddress = origCIVAddress, origControllerAddress
+		civControl = civControlStruct{}
+	})
+	civAddress = 0xa4
+	controllerAddress = 0xe0
+	// some decoders log, like on unknown mode codes
+	quietLog = true
+	log.Init()
+
+	civControl = civControlStruct{}
+	return &civControl
+}
+
+// Does what sendCmd() does with the pending commands, without sending, as there's no serial stream. A
+// command marked later is always sent later, even if the clock didn't advance.
+func markSent(s *civControlStruct, cmd *civCmd) {
+	cmd.pending = true
+	cmd.sentAt = time.Now()
+	for _, c := range s.state.pendingCmds {
+		if c != cmd && !cmd.sentAt.After(c.sentAt) {
+			cmd.sentAt = c.sentAt.Add(time.Nanosecond)
+		}
+	}
+	if isSetCmd(cmd) && !cmd.retried {
+		cmd.unconfirmed++
+	}
+	if s.getPendingCmdIndex(cmd) < 0 {
+		s.state.pendingCmds = append(s.state.pendingCmds, cmd)
+	}
+}
+
+// Truncated frames can come from a bad link or another device on the bus, they must not crash decode().
+func TestDecodeTruncatedPayloads(t *testing.T) {
+	setupCIVTest(t)
+
+	cmds := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x0c, 0x0f, 0x10, 0x13, 0x14, 0x15, 0x16,
+		0x17, 0x1a, 0x1c, 0x21, 0x25, 0x26, 0x27, 0x28, OK, NG}
+	for _, cmd := range cmds {
+		for payloadLen := 0; payloadLen <= 5; payloadLen++ {
+			for first := 0; first < 256; first++ {
+				payload := make([]byte, payloadLen)
+				if payloadLen > 0 {
+					payload[0] = byte(first)
+				} else if first > 0 {
+					break
+				}
+				frame := append([]byte{0xfe, 0xfe, controllerAddress, civAddress, cmd}, payload...)
+				frame = append(frame, 0xfd)
+
+				civControl = civControlStruct{}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("decode(% x) panicked: %v", frame, r)
+						}
+					}()
+					civControl.decode(frame)
+				}()
+			}
+		}
+	}
+}
+
+// A set command is not confirmed by its own echo, so a following NG is matched to it, and not to the
+// next pending command.
+func TestDecodeNGAfterSetCmdEcho(t *testing.T) {
+	s := setupCIVTest(t)
+	s.initCmd(&s.state.setPwr, "setPwr", prepPacket("setPwr", []byte{0x01, 0x28}))
+	markSent(s, &s.state.setPwr)
+	s.initCmd(&s.state.getS, "getS", prepPacket("getS", nil))
+	markSent(s, &s.state.getS)
+
+	s.decode(s.state.setPwr.cmd)
+	if !s.state.setPwr.pending {
+		t.Fatal("setPwr was removed by its echo")
+	}
+
+	s.decode([]byte{0xfe, 0xfe, controllerAddress, civAddress, NG, 0xfd})
+	if s.state.setPwr.pending {
+		t.Error("setPwr is still pending after NG")
+	}
+	if !s.state.getS.pending {
+		t.Error("getS was removed by the NG for setPwr")
+	}
+}
+
+// A set command which was sent twice before the radio answered stays pending until both OK answers
+// arrive, so the second OK is not matched to another pending set command.
+func TestDecodeOKForResentSetCmd(t *testing.T) {
+	s := setupCIVTest(t)
+	s.initCmd(&s.state.setPwr, "setPwr", prepPacket("setPwr", []byte{0x01, 0x28}))
+	markSent(s, &s.state.setPwr)
+	s.initCmd(&s.state.setPwr, "setPwr", prepPacket("setPwr", []byte{0x01, 0x29}))
+	markSent(s, &s.state.setPwr)
+	s.initCmd(&s.state.setRFGain, "setRFGain", prepPacket("setRFGain", []byte{0x02, 0x55}))
+	markSent(s, &s.state.setRFGain)
+
+	ok := []byte{0xfe, 0xfe, controllerAddress, civAddress, OK, 0xfd}
+	s.decode(ok)
+	if !s.state.setPwr.pending || !s.state.setRFGain.pending {
+		t.Fatal("first OK confirmed the wrong cmd")
+	}
+	s.decode(ok)
+	if s.state.setPwr.pending || !s.state.setRFGain.pending {
+		t.Fatal("second OK confirmed the wrong cmd")
+	}
+	s.decode(ok)
+	if s.state.setRFGain.pending {
+		t.Error("setRFGain is still pending after OK")
+	}
+}
+
+func TestRawCmdAnswer(t *testing.T) {
+	s := setupCIVTest(t)
+	s.initCmd(&s.state.setPwr, "setPwr", prepPacket("setPwr", []byte{0x01, 0x28}))
+	markSent(s, &s.state.setPwr)
+	s.initCmd(&s.state.rawCmd, "raw", []byte{0xfe, 0xfe, civAddress, controllerAddress, 0x1a, 0x03, 0x12, 0xfd})
+	markSent(s, &s.state.rawCmd)
+	answerChan := make(chan []byte, 1)
+	s.state.rawCmdAnswerChan = answerChan
+
+	s.decode([]byte{0xfe, 0xfe, controllerAddress, civAddress, 0x1a, 0x05, 0x01, 0x81, 0x01, 0xfd})
+	s.decode([]byte{0xfe, 0xfe, controllerAddress, civAddress, OK, 0xfd})
+	if s.state.setPwr.pending || !s.state.rawCmd.pending || len(answerChan) > 0 {
+		t.Fatal("raw cmd got the answer of another cmd")
+	}
+	s.decode([]byte{0xfe, 0xfe, controllerAddress, civAddress, OK, 0xfd})
+	if s.state.rawCmd.pending || len(answerChan) != 1 {
+		t.Error("raw cmd didn't get its answer")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os/exec"
 	"strings"
 	"syscall"
@@ -25,6 +26,21 @@ func (c *cmdRunner) kill(cmd *exec.Cmd) {
 	}
 }
 
+// Handles a failed start or a non-zero exit of the cmd according to the --exec-fail policy, returns true if
+// the cmd should not be restarted.
+func (c *cmdRunner) handleFailure(cmd *exec.Cmd, err error) (stop bool) {
+	log.Error(cmd, " failed: ", err)
+	switch execFailPolicy {
+	case "stop":
+		log.Error("not restarting ", cmd)
+		return true
+	case "exit":
+		reportFatalError(fmt.Errorf("%v failed: %v", cmd, err))
+		return true
+	}
+	return false
+}
+
 func (c *cmdRunner) run(cmdLine string) {
 	var cmd *exec.Cmd
 
@@ -47,7 +63,12 @@ func (c *cmdRunner) run(cmdLine string) {
 		cmd = exec.Command(s[0], s[1:]...)
 		err := cmd.Start()
 		if err != nil {
-			log.Error("error starting ", cmd)
+			stop := c.handleFailure(cmd, err)
+			cmd = nil
+			if stop {
+				<-c.runEndNeeded
+				return
+			}
 			continue
 		}
 
@@ -63,8 +84,11 @@ func (c *cmdRunner) run(cmdLine string) {
 			log.Debug("restarting ", cmd)
 			c.kill(cmd)
 		case err := <-finishedChan:
-			if err != nil {
-				log.Error(cmd, " error: ", err)
+			finishedCmd := cmd
+			cmd = nil // no need to kill it
+			if err != nil && c.handleFailure(finishedCmd, err) {
+				<-c.runEndNeeded
+				return
 			}
 		case <-c.runEndNeeded:
 			return
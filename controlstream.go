@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"errors"
+	"strings"
 	"time"
 )
 
@@ -273,11 +274,23 @@ func (s *controlStream) handleRead(r []byte) error {
 
 			runCmdRunner.startIfNeeded(runCmd)
 			if enableSerialDevice {
-				serialCmdRunner.startIfNeeded(runCmdOnSerialPortCreated)
+				statusLog.reportSerialDevice(serialPort.symlink)
+				serialCmdRunner.startIfNeeded(strings.ReplaceAll(runCmdOnSerialPortCreated, "{pty}", serialPort.symlink))
 			}
 			if err := rigctld.initIfNeeded(); err != nil {
 				return err
 			}
+			if err := civCmdSrv.initIfNeeded(); err != nil {
+				return err
+			}
+			tts.initIfNeeded()
+			statusJSON.initIfNeeded()
+			if err := extPTT.initIfNeeded(); err != nil {
+				return err
+			}
+			if err := sessionLog.initIfNeeded(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -285,6 +298,7 @@ func (s *controlStream) handleRead(r []byte) error {
 
 func (s *controlStream) loop() {
 	netstat.reset()
+	sessionStats.start()
 
 	s.reauthTimeoutTimer = time.NewTimer(0)
 	<-s.reauthTimeoutTimer.C
@@ -307,6 +321,12 @@ func (s *controlStream) loop() {
 			}
 		case <-s.reauthTimeoutTimer.C:
 			log.Error("auth timeout, audio/serial stream may stop")
+		case <-s.audio.stalledChan:
+			if s.serialAndAudioStreamOpened && !s.deinitializing {
+				if err := s.audio.restart(); err != nil {
+					reportError(errors.New("audio/" + err.Error()))
+				}
+			}
 		case <-s.deinitNeededChan:
 			s.deinitFinishedChan <- true
 			return
@@ -401,4 +421,6 @@ func (s *controlStream) deinit() {
 	s.common.deinit()
 	s.serial.deinit()
 	s.audio.deinit()
+
+	sessionStats.print()
 }
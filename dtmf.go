@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The CI-V protocol has no commands for reading received and sending DTMF digits, so they are detected
+// from the received audio stream using the Goertzel algorithm, and sent as generated audio.
+
+const dtmfMinAvgPower = 200 * 200 // Blocks with lower average sample power are considered silence.
+const dtmfMinToneRatio = 0.6      // Min. ratio of the two tones' power in the whole block power.
+const dtmfMaxLastDigits = 16
+const dtmfDisplayDuration = 30 * time.Second
+
+var dtmfRowFreqs = [4]float64{697, 770, 852, 941}
+var dtmfColFreqs = [4]float64{1209, 1336, 1477, 1633}
+var dtmfDigits = [4][4]byte{
+	{'1', '2', '3', 'A'},
+	{'4', '5', '6', 'B'},
+	{'7', '8', '9', 'C'},
+	{'*', '0', '#', 'D'},
+}
+
+type dtmfDetectorStruct struct {
+	// 20ms blocks, giving 50Hz frequency resolution.
+	blockSize int
+	block     []float64
+	rowCoeff  [4]float64
+	colCoeff  [4]float64
+
+	// The digit detected in the previous block, 0 if there was none.
+	prevDigit byte
+	// The last reported digit, 0 after a block without a digit.
+	reportedDigit byte
+}
+
+var dtmfDetector dtmfDetectorStruct
+
+func (d *dtmfDetectorStruct) init() {
+	for i := range dtmfRowFreqs {
+		d.rowCoeff[i] = 2 * math.Cos(2*math.Pi*dtmfRowFreqs[i]/float64(audioSampleRate))
+		d.colCoeff[i] = 2 * math.Cos(2*math.Pi*dtmfColFreqs[i]/float64(audioSampleRate))
+	}
+	d.blockSize = audioSampleRate / 50
+	d.block = make([]float64, 0, d.blockSize)
+}
+
+func (d *dtmfDetectorStruct) goertzel(coeff float64) float64 {
+	var s1, s2 float64
+	for _, x := range d.block {
+		s0 := x + coeff*s1 - s2
+		s2 = s1
+		s1 = s0
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// Returns the index and the power of the strongest frequency.
+func (d *dtmfDetectorStruct) strongest(coeffs [4]float64) (idx int, power float64) {
+	for i := range coeffs {
+		p := d.goertzel(coeffs[i])
+		if p > power {
+			idx = i
+			power = p
+		}
+	}
+	return
+}
+
+// Returns the DTMF digit found in the current block, or 0.
+func (d *dtmfDetectorStruct) detectInBlock() byte {
+	var energy float64
+	for _, x := range d.block {
+		energy += x * x
+	}
+	if energy/float64(len(d.block)) < dtmfMinAvgPower {
+		return 0
+	}
+
+	rowIdx, rowPower := d.strongest(d.rowCoeff)
+	colIdx, colPower := d.strongest(d.colCoeff)
+
+	// The Goertzel power of a sine wave is N/2 times its energy in the block.
+	norm := energy * float64(len(d.block)) / 2
+	if (rowPower+colPower)/norm < dtmfMinToneRatio || rowPower/norm < dtmfMinToneRatio/4 ||
+		colPower/norm < dtmfMinToneRatio/4 {
+		return 0
+	}
+	return dtmfDigits[rowIdx][colIdx]
+}
+
+// A digit is reported when it's detected in two consecutive blocks, and it won't be reported again
+// until a block without it is received.
+func (d *dtmfDetectorStruct) processBlock() {
+	digit := d.detectInBlock()
+	if digit != 0 && digit == d.prevDigit && digit != d.reportedDigit {
+		d.reportedDigit = digit
+		civControl.reportDTMF(digit)
+	}
+	if digit != d.prevDigit {
+		d.reportedDigit = 0
+	}
+	d.prevDigit = digit
+}
+
+// Expects s16le PCM data.
+func (d *dtmfDetectorStruct) process(pcm []byte) {
+	if d.block == nil {
+		d.init()
+	}
+
+	for i := 0; i+1 < len(pcm); i += 2 {
+		d.block = append(d.block, float64(int16(binary.LittleEndian.Uint16(pcm[i:i+2]))))
+		if len(d.block) == d.blockSize {
+			d.processBlock()
+			d.block = d.block[:0]
+		}
+	}
+}
+
+const dtmfToneLength = 100 * time.Millisecond
+const dtmfGapLength = 100 * time.Millisecond
+const dtmfTxLeadIn = 300 * time.Millisecond
+const dtmfToneAmplitude = 8000
+
+type dtmfSenderStruct struct {
+	mutex   sync.Mutex
+	sending bool
+}
+
+var dtmfSender dtmfSenderStruct
+
+// The mic audio is muted while sending, so it won't get mixed with the tones.
+func (d *dtmfSenderStruct) isSending() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.sending
+}
+
+func (d *dtmfSenderStruct) getFreqs(digit byte) (rowFreq, colFreq float64, found bool) {
+	for r := range dtmfDigits {
+		for c := range dtmfDigits[r] {
+			if dtmfDigits[r][c] == digit {
+				return dtmfRowFreqs[r], dtmfColFreqs[c], true
+			}
+		}
+	}
+	return
+}
+
+// Returns the PCM data of the given digit's tone followed by silence, padded to whole audio frames.
+func (d *dtmfSenderStruct) generate(digit byte) []byte {
+	rowFreq, colFreq, _ := d.getFreqs(digit)
+	toneSamples := int(time.Duration(audioSampleRate) * dtmfToneLength / time.Second)
+	allSamples := int(time.Duration(audioSampleRate) * (dtmfToneLength + dtmfGapLength) / time.Second)
+	frameSamples := audioFrameSize / audioSampleBytes
+	allSamples = ((allSamples + frameSamples - 1) / frameSamples) * frameSamples
+
+	pcm := make([]byte, allSamples*audioSampleBytes)
+	for i := 0; i < toneSamples; i++ {
+		t := float64(i) / float64(audioSampleRate)
+		v := dtmfToneAmplitude * (math.Sin(2*math.Pi*rowFreq*t) + math.Sin(2*math.Pi*colFreq*t))
+		binary.LittleEndian.PutUint16(pcm[i*audioSampleBytes:], uint16(int16(v)))
+	}
+	return pcm
+}
+
+func (d *dtmfSenderStruct) send(digits string) {
+	defer func() {
+		d.mutex.Lock()
+		d.sending = false
+		d.mutex.Unlock()
+	}()
+
+	civControl.state.mutex.Lock()
+	wasPTT := civControl.state.ptt
+	civControl.state.mutex.Unlock()
+
+	if !wasPTT {
+		if err := civControl.setPTT(true); err != nil {
+			log.Error("can't turn on ptt: ", err)
+			return
+		}
+		time.Sleep(dtmfTxLeadIn)
+	}
+
+	ticker := time.NewTicker(audioFrameLength)
+	defer ticker.Stop()
+	for i := range digits {
+		pcm := d.generate(digits[i])
+		for len(pcm) > 0 {
+			<-ticker.C
+			audio.rec <- pcm[:audioFrameSize]
+			pcm = pcm[audioFrameSize:]
+		}
+	}
+
+	if !wasPTT {
+		if err := civControl.setPTT(false); err != nil {
+			log.Error("can't turn off ptt: ", err)
+		}
+	}
+}
+
+// Transmits the given DTMF digits (0-9, A-D, * and #) using the audio stream.
+func sendDTMF(digits string) error {
+	digits = strings.ToUpper(digits)
+	if digits == "" {
+		return errors.New("no dtmf digits given")
+	}
+	for i := range digits {
+		if _, _, found := dtmfSender.getFreqs(digits[i]); !found {
+			return fmt.Errorf("invalid dtmf digit %c", digits[i])
+		}
+	}
+	if audio.rec == nil {
+		return errors.New("audio stream is not running")
+	}
+
+	dtmfSender.mutex.Lock()
+	defer dtmfSender.mutex.Unlock()
+	if dtmfSender.sending {
+		return errors.New("dtmf sending already in progress")
+	}
+	dtmfSender.sending = true
+
+	log.Print("sending dtmf ", digits)
+	go dtmfSender.send(digits)
+	return nil
+}
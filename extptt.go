@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+const extPTTPollInterval = 10 * time.Millisecond
+const extPTTDebounce = 50 * time.Millisecond
+
+// If the --ext-ptt option is set, then PTT is driven by an external input, like a foot-switch wired to
+// a GPIO or a serial control line. The input is either a file which is polled (for example a GPIO
+// value file in /sys/class/gpio), or a named pipe from which lines are read. In both cases "1" means
+// high and "0" means low level. The input has to be stable for extPTTDebounce before PTT is changed,
+// so switch bounce doesn't key the transceiver on and off rapidly.
+type extPTTStruct struct {
+	deinitNeededChan   chan bool
+	deinitFinishedChan chan bool
+
+	pipe      *os.File
+	levelChan chan bool
+
+	level          bool // the last raw input level
+	levelChangedAt time.Time
+	active         bool // the debounced state
+}
+
+var extPTT extPTTStruct
+
+func (e *extPTTStruct) parseLevel(s string) (level bool, ok bool) {
+	switch strings.TrimSpace(s) {
+	case "1":
+		return true, true
+	case "0":
+		return false, true
+	}
+	return false, false
+}
+
+func (e *extPTTStruct) readFile() (level bool, ok bool) {
+	d, err := os.ReadFile(extPTTPath)
+	if err != nil {
+		return false, false
+	}
+	return e.parseLevel(string(d))
+}
+
+func (e *extPTTStruct) pipeReader() {
+	scanner := bufio.NewScanner(e.pipe)
+	for scanner.Scan() {
+		if level, ok := e.parseLevel(scanner.Text()); ok {
+			e.levelChan <- level
+		}
+	}
+}
+
+func (e *extPTTStruct) setLevel(level bool) {
+	if level != e.level {
+		e.level = level
+		e.levelChangedAt = time.Now()
+	}
+}
+
+func (e *extPTTStruct) update() {
+	active := e.level != extPTTActiveLow
+	if active == e.active || time.Since(e.levelChangedAt) < extPTTDebounce {
+		return
+	}
+	e.active = active
+
+	if active {
+		log.Print("external ptt on")
+	} else {
+		log.Print("external ptt off")
+	}
+	// With --set-data-tx the audio is recorded from the default soundcard like with the space hotkey,
+	// otherwise only PTT is changed, so the radio's own mic is used.
+	if setDataModeOnTx {
+		audio.setRecFromDefaultSoundcard(active)
+		return
+	}
+	if err := civControl.setPTT(active); err != nil {
+		log.Error("can't change ptt: ", err)
+	}
+}
+
+func (e *extPTTStruct) loop() {
+	ticker := time.NewTicker(extPTTPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case level := <-e.levelChan:
+			e.setLevel(level)
+		case <-ticker.C:
+			if e.pipe == nil {
+				if level, ok := e.readFile(); ok {
+					e.setLevel(level)
+				}
+			}
+			e.update()
+		case <-e.deinitNeededChan:
+			e.deinitFinishedChan <- true
+			return
+		}
+	}
+}
+
+func (e *extPTTStruct) initIfNeeded() error {
+	if e.deinitNeededChan != nil || extPTTPath == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(extPTTPath)
+	if err != nil {
+		return err
+	}
+
+	// The inactive level is assumed at start, so PTT is not changed until the input changes.
+	e.level = extPTTActiveLow
+	e.active = false
+	e.levelChan = make(chan bool)
+	if fi.Mode()&os.ModeNamedPipe != 0 {
+		// Opening for writing too, so the open doesn't block and we don't get EOF when writers disconnect.
+		if e.pipe, err = os.OpenFile(extPTTPath, os.O_RDWR, 0); err != nil {
+			return err
+		}
+		go e.pipeReader()
+		log.Print("reading external ptt from named pipe ", extPTTPath)
+	} else {
+		log.Print("polling external ptt from ", extPTTPath)
+	}
+
+	e.deinitNeededChan = make(chan bool)
+	e.deinitFinishedChan = make(chan bool)
+	go e.loop()
+	return nil
+}
+
+func (e *extPTTStruct) deinit() {
+	// Closing the pipe first, so the pipe reader exits while the loop still receives its levels.
+	if e.pipe != nil {
+		e.pipe.Close()
+	}
+	if e.deinitNeededChan != nil {
+		e.deinitNeededChan <- true
+		<-e.deinitFinishedChan
+		e.deinitNeededChan = nil
+	}
+	e.pipe = nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A frequency can be entered directly, or as a band and mode shortcut like "40cw" or "20ssb", which
+// jumps to the conventional operating frequency for that band and mode, and sets the mode.
+
+// Shortcut frequencies (IARU Region 1), keyed by band name and mode (see quickFreqKey()). They can be
+// changed with a command line argument.
+var quickFreqs = map[string]uint64{
+	"160m/cw":   1830000,
+	"160m/rtty": 1840000,
+	"160m/ssb":  1850000,
+	"80m/cw":    3530000,
+	"80m/rtty":  3590000,
+	"80m/ssb":   3700000,
+	"40m/cw":    7030000,
+	"40m/rtty":  7040000,
+	"40m/ssb":   7150000,
+	"30m/cw":    10116000,
+	"20m/cw":    14030000,
+	"20m/rtty":  14080000,
+	"20m/ssb":   14250000,
+	"17m/cw":    18080000,
+	"17m/ssb":   18130000,
+	"15m/cw":    21030000,
+	"15m/rtty":  21080000,
+	"15m/ssb":   21300000,
+	"12m/cw":    24900000,
+	"12m/ssb":   24950000,
+	"10m/cw":    28030000,
+	"10m/rtty":  28080000,
+	"10m/ssb":   28500000,
+	"10m/fm":    29600000,
+	"6m/cw":     50090000,
+	"6m/ssb":    50150000,
+	"6m/fm":     51510000,
+	"2m/cw":     144050000,
+	"2m/ssb":    144300000,
+	"2m/fm":     145500000,
+	"2m/dv":     145375000,
+	"70cm/ssb":  432200000,
+	"70cm/fm":   433500000,
+	"70cm/dv":   438500000,
+}
+
+// Below this frequency SSB means LSB, above it USB.
+const ssbUSBMinFreq = 10000000
+
+func quickFreqKey(bandIdx int, mode string) string {
+	return civBands[bandIdx].name + "/" + mode
+}
+
+// Parses a band and mode shortcut like "40cw", "40mcw" or "70cmfm". The mode is "ssb" or an operating
+// mode name, returned in lower case.
+func parseQuickFreqShortcut(str string) (bandIdx int, mode string, err error) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	i := 0
+	for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return -1, "", fmt.Errorf("no band in %s", str)
+	}
+	num, rest := str[:i], str[i:]
+
+	// No mode name starts with m or cm, so the band's unit can be stripped.
+	units := []string{"m", "cm"}
+	if strings.HasPrefix(rest, "cm") {
+		units = []string{"cm"}
+		rest = rest[2:]
+	} else if strings.HasPrefix(rest, "m") {
+		units = []string{"m"}
+		rest = rest[1:]
+	}
+	bandIdx = -1
+	for _, unit := range units {
+		for j := range civBands {
+			if civBands[j].name == num+unit {
+				bandIdx = j
+				break
+			}
+		}
+		if bandIdx >= 0 {
+			break
+		}
+	}
+	if bandIdx < 0 {
+		return -1, "", fmt.Errorf("unknown band %s", num)
+	}
+
+	if rest == "ssb" {
+		return bandIdx, rest, nil
+	}
+	for j := range civOperatingModes {
+		if strings.ToLower(civOperatingModes[j].name) == rest {
+			return bandIdx, rest, nil
+		}
+	}
+	return -1, "", fmt.Errorf("unknown mode %s", rest)
+}
+
+// Parses a directly entered frequency. With a decimal point it's in MHz (14.074), below 1000000 it's in
+// kHz (14074), otherwise in Hz.
+func parseFreqEntry(str string) (uint64, error) {
+	str = strings.TrimSpace(str)
+	if strings.Contains(str, ".") {
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil || f <= 0 {
+			return 0, fmt.Errorf("can't parse frequency %s", str)
+		}
+		return uint64(f*1000000 + 0.5), nil
+	}
+	f, err := strconv.ParseUint(str, 10, 64)
+	if err != nil || f == 0 {
+		return 0, fmt.Errorf("can't parse frequency %s", str)
+	}
+	if f < 1000000 {
+		f *= 1000
+	}
+	return f, nil
+}
+
+// Returns the operating mode index for a shortcut mode name.
+func getQuickFreqModeIdx(mode string, freq uint64) int {
+	if mode == "ssb" {
+		mode = "usb"
+		if freq < ssbUSBMinFreq {
+			mode = "lsb"
+		}
+	}
+	for i := range civOperatingModes {
+		if strings.ToLower(civOperatingModes[i].name) == mode {
+			return i
+		}
+	}
+	return -1
+}
+
+// Tunes to a directly entered frequency, or to the frequency of a band and mode shortcut.
+func tuneToFreqEntry(str string) error {
+	if str == "" {
+		return errors.New("nothing entered")
+	}
+
+	if f, err := parseFreqEntry(str); err == nil {
+		log.Print("tuning to ", f)
+		return civControl.setMainVFOFreq(f)
+	}
+
+	bandIdx, mode, err := parseQuickFreqShortcut(str)
+	if err != nil {
+		return err
+	}
+	f := quickFreqs[quickFreqKey(bandIdx, mode)]
+	if f == 0 {
+		// Using the last frequency of the band, if there's no shortcut frequency for this mode.
+		f = civBands[bandIdx].freq
+	}
+	if f == 0 {
+		return fmt.Errorf("no frequency set for %s", quickFreqKey(bandIdx, mode))
+	}
+
+	modeIdx := getQuickFreqModeIdx(mode, f)
+	log.Print("tuning to ", f, " ", civOperatingModes[modeIdx].name)
+	if err := civControl.setMainVFOFreq(f); err != nil {
+		return err
+	}
+	if modeIdx == civControl.state.operatingModeIdx {
+		return nil
+	}
+	return civControl.setOperatingModeAndFilter(civOperatingModes[modeIdx].code,
+		civFilters[civControl.state.filterIdx].code)
+}
@@ -1,9 +1,109 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Terminals can't tell us when a key is released, so a tuning key is considered held while it's
+// repeated faster than this. The first repeat comes after the keyboard's repeat delay.
+const tuningAccelRepeatTimeout = 600 * time.Millisecond
+
+// The tuning step multiplier is increased by one after every interval the key is held.
+const tuningAccelInterval = 500 * time.Millisecond
+
+type tuningAccelStruct struct {
+	key       byte
+	heldSince time.Time
+	lastAt    time.Time
+}
+
+var tuningAccel tuningAccelStruct
+
+// Returns how many tuning steps should be done for the given key press.
+func (t *tuningAccelStruct) getSteps(k byte) uint {
+	now := time.Now()
+	if k != t.key || now.Sub(t.lastAt) > tuningAccelRepeatTimeout {
+		t.key = k
+		t.heldSince = now
+	}
+	t.lastAt = now
+
+	steps := 1 + uint(now.Sub(t.heldSince)/tuningAccelInterval)
+	if steps > maxTuningAccel {
+		steps = maxTuningAccel
+	}
+	return steps
+}
+
+// Some hotkeys need a text input, while the input is active keys are not handled as hotkeys.
+type hotkeyInputStruct struct {
+	active  bool
+	prompt  string
+	buf     string
+	onEnter func(str string)
+}
+
+var hotkeyInput hotkeyInputStruct
+
+func startHotkeyInput(prompt string, onEnter func(str string)) {
+	hotkeyInput = hotkeyInputStruct{
+		active:  true,
+		prompt:  prompt,
+		onEnter: onEnter,
+	}
+	statusLog.reportInput(prompt + "> _")
+}
+
+func handleHotkeyInput(k byte) {
+	switch k {
+	case '\n':
+		hotkeyInput.active = false
+		statusLog.reportInput("")
+		hotkeyInput.onEnter(hotkeyInput.buf)
+		return
+	case 0x1b: // Esc cancels the input.
+		hotkeyInput.active = false
+		statusLog.reportInput("")
+		return
+	case 0x7f, 0x08: // Backspace
+		if len(hotkeyInput.buf) > 0 {
+			hotkeyInput.buf = hotkeyInput.buf[:len(hotkeyInput.buf)-1]
+		}
+	default:
+		if k >= 0x20 && k < 0x7f {
+			hotkeyInput.buf += string(k)
+		}
+	}
+	statusLog.reportInput(hotkeyInput.prompt + "> " + hotkeyInput.buf + "_")
+}
 
 func handleHotkey(k byte) {
+	civControl.reportUserAction()
+
+	if hotkeyInput.active {
+		handleHotkeyInput(k)
+		return
+	}
+
 	switch k {
+	case 'A':
+		if err := civControl.announceAll(); err != nil {
+			log.Error("can't announce: ", err)
+		}
+	case 'F':
+		if err := civControl.announceFrequency(); err != nil {
+			log.Error("can't announce frequency: ", err)
+		}
+	case 'M':
+		if err := civControl.announceMode(); err != nil {
+			log.Error("can't announce mode: ", err)
+		}
+	case 'N':
+		log.Print(netstat.getRetransmitBreakdown())
+	case 'C':
+		log.Print(civControl.getPendingCmdsStr())
 	case 'c':
 		// provide a way to clear the screen since sometimes the stack of errors gets to be rather distracting
 		fmt.Printf("%v", termDetail.eraseScreen)
@@ -15,6 +115,10 @@ func handleHotkey(k byte) {
 		if err := civControl.toggleAntennaTuner(); err != nil {
 			log.Error("can't toggle tune: ", err)
 		}
+	case 'u':
+		if err := civControl.toggleTunerEnabled(); err != nil {
+			log.Error("can't toggle tuner: ", err)
+		}
 	case '+':
 		if err := civControl.incPwr(); err != nil {
 			log.Error("can't increase power: ", err)
@@ -119,6 +223,14 @@ func handleHotkey(k byte) {
 		if err := civControl.decSQL(); err != nil {
 			log.Error("can't decrease sql: ", err)
 		}
+	case '|':
+		if err := civControl.incSensitivity(); err != nil {
+			log.Error("can't increase sensitivity: ", err)
+		}
+	case '\\':
+		if err := civControl.decSensitivity(); err != nil {
+			log.Error("can't decrease sensitivity: ", err)
+		}
 	case '.':
 		if err := civControl.incNR(); err != nil {
 			log.Error("can't increase nr: ", err)
@@ -131,12 +243,35 @@ func handleHotkey(k byte) {
 		if err := civControl.toggleNR(); err != nil {
 			log.Error("can't toggle nr: ", err)
 		}
+	case '>':
+		if err := civControl.incNB(); err != nil {
+			log.Error("can't increase nb: ", err)
+		}
+	case '<':
+		if err := civControl.decNB(); err != nil {
+			log.Error("can't decrease nb: ", err)
+		}
+	case '?':
+		if err := civControl.toggleNB(); err != nil {
+			log.Error("can't toggle nb: ", err)
+		}
+	case 'J':
+		startHotkeyInput("NB depth (1-10) and width (1-100), like 5/50", func(str string) {
+			var depth, width int
+			if _, err := fmt.Sscanf(str, "%d/%d", &depth, &width); err != nil {
+				log.Error("invalid nb depth and width: ", str)
+				return
+			}
+			if err := civControl.setNBDepthWidth(depth, width); err != nil {
+				log.Error("can't set nb depth and width: ", err)
+			}
+		})
 	case ']':
-		if err := civControl.incFreq(); err != nil {
+		if err := civControl.incFreq(tuningAccel.getSteps(k)); err != nil {
 			log.Error("can't increase freq: ", err)
 		}
 	case '[':
-		if err := civControl.decFreq(); err != nil {
+		if err := civControl.decFreq(tuningAccel.getSteps(k)); err != nil {
 			log.Error("can't decrease freq: ", err)
 		}
 	case '}':
@@ -171,6 +306,10 @@ func handleHotkey(k byte) {
 		if err := civControl.incBand(); err != nil {
 			log.Error("can't change band: ", err)
 		}
+	case 'B':
+		if err := civControl.copyVFOAtoB(); err != nil {
+			log.Error("can't copy VFO A to B: ", err)
+		}
 	case 'v':
 		if err := civControl.decBand(); err != nil {
 			log.Error("can't change band: ", err)
@@ -179,6 +318,10 @@ func handleHotkey(k byte) {
 		if err := civControl.togglePreamp(); err != nil {
 			log.Error("can't change preamp: ", err)
 		}
+	case 'z':
+		if err := civControl.toggleAttenuator(); err != nil {
+			log.Error("can't toggle attenuator: ", err)
+		}
 	case 'a':
 		if err := civControl.toggleAGC(); err != nil {
 			log.Error("can't change agc: ", err)
@@ -191,6 +334,84 @@ func handleHotkey(k byte) {
 		if err := civControl.toggleSplit(); err != nil {
 			log.Error("can't change split: ", err)
 		}
+	case 'L':
+		if err := civControl.toggleDialLock(); err != nil {
+			log.Error("can't change dial lock: ", err)
+		}
+	case 'w':
+		if err := civControl.toggleDualWatch(); err != nil {
+			log.Error("can't change dual watch: ", err)
+		}
+	case 'r':
+		if err := civControl.toggleRIT(); err != nil {
+			log.Error("can't change rit: ", err)
+		}
+	case 'h':
+		if err := civControl.toggleScopeHold(); err != nil {
+			log.Error("can't change scope hold: ", err)
+		}
+	case 'R':
+		if err := civControl.clearRIT(); err != nil {
+			log.Error("can't clear rit: ", err)
+		}
+	case 'j':
+		if err := civControl.nudgeRIT(-ritNudgeStep); err != nil {
+			log.Error("can't change rit: ", err)
+		}
+	case 'k':
+		if err := civControl.nudgeRIT(ritNudgeStep); err != nil {
+			log.Error("can't change rit: ", err)
+		}
+	case 'x':
+		if err := civControl.toggleXIT(); err != nil {
+			log.Error("can't change xit: ", err)
+		}
+	case 'S':
+		if err := spots.store(); err != nil {
+			log.Error("can't store spot: ", err)
+		}
+	case 'g':
+		if err := spots.next(); err != nil {
+			log.Error("can't tune to spot: ", err)
+		}
+	case 'V':
+		startHotkeyInput("Voice memory (1-8, 0 stops)", func(str string) {
+			slot, err := strconv.ParseUint(str, 10, 8)
+			if err != nil {
+				log.Error("invalid voice memory slot: ", str)
+				return
+			}
+			if slot == 0 {
+				err = civControl.stopVoiceMemory()
+			} else {
+				err = civControl.playVoiceMemory(byte(slot))
+			}
+			if err != nil {
+				log.Error("can't play voice memory: ", err)
+			}
+		})
+	case 'K':
+		startHotkeyInput("Keyer memory (1-8, 0 stops)", func(str string) {
+			slot, err := strconv.ParseUint(str, 10, 8)
+			if err != nil {
+				log.Error("invalid keyer memory slot: ", str)
+				return
+			}
+			if slot == 0 {
+				err = civControl.stopCWMsg()
+			} else {
+				err = civControl.sendKeyerMemory(byte(slot))
+			}
+			if err != nil {
+				log.Error("can't send keyer memory: ", err)
+			}
+		})
+	case 'T':
+		startHotkeyInput("DTMF", func(digits string) {
+			if err := sendDTMF(digits); err != nil {
+				log.Error("can't send dtmf: ", err)
+			}
+		})
 	case '\n':
 		if statusLog.isRealtime() {
 			statusLog.mutex.Lock()
@@ -199,6 +420,95 @@ func handleHotkey(k byte) {
 			statusLog.mutex.Unlock()
 			statusLog.print()
 		}
+	case 'H':
+		if err := civControl.goHome(); err != nil {
+			log.Error("can't tune to home: ", err)
+		}
+	case 'W':
+		if err := civControl.tuneToNextTimeStation(); err != nil {
+			log.Error("can't tune to time station: ", err)
+		}
+	case 'y':
+		if err := civControl.cycleTXDelay(); err != nil {
+			log.Error("can't change tx delay: ", err)
+		}
+	case 'I':
+		if err := civControl.toggleTXInhibit(); err != nil {
+			log.Error("can't change tx inhibit: ", err)
+		}
+	case 'Q':
+		if err := civControl.swapLastFreq(); err != nil {
+			log.Error("can't swap to last frequency: ", err)
+		}
+	case 'E':
+		if err := civControl.resetPBT(); err != nil {
+			log.Error("can't reset pbt: ", err)
+		}
+	case 'X':
+		if err := civControl.toggleTransceive(); err != nil {
+			log.Error("can't toggle transceive: ", err)
+		}
+	case 'O':
+		if err := civControl.toggleOVFPolling(); err != nil {
+			log.Error("can't toggle OVF polling: ", err)
+		}
+	case 'P':
+		startHotkeyInput("Power off the radio? (y/n)", func(str string) {
+			if str != "y" {
+				return
+			}
+			if err := civControl.powerOffRadio(); err != nil {
+				log.Error("can't power off the radio: ", err)
+			}
+		})
+	case 'e':
+		startHotkeyInput("Frequency (MHz with a dot, kHz, Hz) or band+mode (like 40cw)", func(str string) {
+			if err := tuneToFreqEntry(str); err != nil {
+				log.Error("can't tune: ", err)
+			}
+		})
+	case 'i':
+		mode := civControl.getOperatingModeName()
+		startHotkeyInput("FIL1/FIL2/FIL3 widths for "+mode+" in Hz (empty for the configured ones)", func(str string) {
+			var widths []int
+			if str == "" {
+				widths = civModeFilterWidths[mode]
+				if widths == nil {
+					log.Error("no filter widths configured for ", mode)
+					return
+				}
+			} else {
+				var err error
+				if widths, err = parseFilterWidths(str); err != nil {
+					log.Error("invalid filter widths: ", err)
+					return
+				}
+			}
+			// Setting the filters takes a while, as the radio's answers are waited for.
+			go func() {
+				if err := civControl.setFilterWidths(widths); err != nil {
+					log.Error("can't set filter widths: ", err)
+				}
+			}()
+		})
+	case 'G':
+		if report, err := civControl.getSignalReport(); err != nil {
+			log.Error("can't get signal report: ", err)
+		} else {
+			log.Print("signal report: ", report)
+			sessionLog.event("signal report ", report)
+		}
+	case 'Z':
+		startHotkeyInput(fmt.Sprintf("CI-V address (now %02x)", civAddress), func(str string) {
+			addr, err := parseCIVAddress(str)
+			if err != nil {
+				log.Error("invalid CI-V address: ", str)
+				return
+			}
+			if err := switchCIVAddress(addr); err != nil {
+				log.Error("can't switch CI-V address: ", err)
+			}
+		})
 	case 'q':
 		quitChan <- true
     default:
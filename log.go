@@ -83,7 +83,10 @@ func (l *logger) Init() {
 	pe := zap.NewProductionEncoderConfig()
 	pe.EncodeTime = zapcore.ISO8601TimeEncoder
 	// pe.LevelKey = ""
-	consoleEncoder := zapcore.NewConsoleEncoder(pe)
+	encoder := zapcore.NewConsoleEncoder(pe)
+	if headless { // Structured logs for log collectors like journald.
+		encoder = zapcore.NewJSONEncoder(pe)
+	}
 
 	var level zapcore.Level
 	if verboseLog {
@@ -94,7 +97,7 @@ func (l *logger) Init() {
 		level = zap.InfoLevel
 	}
 
-	core := zapcore.NewCore(consoleEncoder, zapcore.AddSync(os.Stdout), level)
+	core := zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), level)
 	l.logger = zap.New(core).Sugar()
 
 	var callerFilename string
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
 	"runtime/debug"
@@ -15,6 +16,40 @@ const waitOnRetryFailure = 65 * time.Second
 
 var gotErrChan = make(chan bool)
 var quitChan = make(chan bool)
+var fatalErrChan = make(chan error, 1)
+
+// These can be set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.buildDate=...".
+// If they are not set, then they are read from the build info embedded by the Go toolchain.
+var version string
+var commit string
+var buildDate string
+
+func getVersionStr() string {
+	v, c, d := version, commit, buildDate
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && c == "":
+				c = s.Value
+			case s.Key == "vcs.time" && d == "":
+				d = s.Value
+			}
+		}
+	}
+	if v == "" {
+		v = "(devel)"
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return "kappanhang " + v + " commit " + c + " built " + d
+}
 
 func getAboutStr() string {
 	var v string
@@ -30,6 +65,51 @@ func getAboutStr() string {
 	return about_msg
 }
 
+// Logs the effective configuration after the command line arguments are parsed, so it's easy to see why
+// a feature is not working.
+func logConfig() {
+	log.Print(fmt.Sprintf("config: radio %s, CI-V address %02x, controller address %02x", connectAddress, civAddress,
+		controllerAddress))
+
+	features := []string{fmt.Sprint("serial tcp port ", serialTCPPort), fmt.Sprint("rigctld port ", rigctldPort)}
+	if enableSerialDevice {
+		path := serialDevicePath
+		if path == "" {
+			path = "default path"
+		}
+		features = append(features, "virtual serial port ("+path+")")
+	}
+	if civCmdPort != 0 {
+		features = append(features, fmt.Sprint("CI-V cmd port ", civCmdPort))
+	}
+	if statusJSONFile != "" {
+		features = append(features, "status JSON "+statusJSONFile)
+	}
+	if stateCSVFile != "" {
+		features = append(features, "state CSV "+stateCSVFile)
+	}
+	if sessionLogFile != "" {
+		features = append(features, "session log "+sessionLogFile)
+	}
+	if extPTTPath != "" {
+		features = append(features, "external PTT "+extPTTPath)
+	}
+	if ttsCmd != "" {
+		features = append(features, "TTS")
+	}
+	if headless {
+		features = append(features, "headless")
+	}
+	log.Print("config: ", strings.Join(features, ", "))
+
+	poll := fmt.Sprint("poll interval ", statusPollInterval)
+	if idlePollAfter > 0 {
+		poll += fmt.Sprint(" (", idlePollInterval, " after ", idlePollAfter, " idle)")
+	}
+	log.Print("config: ", poll, ", status interval ", statusLogInterval, ", band plan built-in (", len(civBands),
+		" bands)")
+}
+
 func wait(d time.Duration, osSignal chan os.Signal) (shouldExit bool) {
 	for sec := d.Seconds(); sec > 0; sec-- {
 		log.Print("waiting ", sec, " seconds...")
@@ -70,15 +150,49 @@ func runControlStream(osSignal chan os.Signal) (requireWait, shouldExit bool, ex
 	// Need to wait before reinit because the IC-705 will disconnect our audio stream eventually
 	//   if we relogin in a too short interval without a deauth...
 	case requireWait = <-gotErrChan:
+		radioPowerSave.restoreIfNeeded()
 		ctrl.deinit()
+		// The error may be caused by a fatal error (like a failed self test), which makes us exit.
+		select {
+		case err := <-fatalErrChan:
+			log.Error(err)
+			return false, true, 1
+		default:
+		}
 		return
 	case <-osSignal:
 		log.Print("sigterm received")
+		radioPowerSave.restoreIfNeeded()
+		powerOffRadioIfNeeded()
 		ctrl.deinit()
 		return false, true, 0
 	case <-quitChan:
+		radioPowerSave.restoreIfNeeded()
+		powerOffRadioIfNeeded()
 		ctrl.deinit()
 		return false, true, 0
+	case err := <-fatalErrChan:
+		log.Error(err)
+		radioPowerSave.restoreIfNeeded()
+		ctrl.deinit()
+		return false, true, 1
+	}
+}
+
+func powerOffRadioIfNeeded() {
+	if !powerOffOnExit {
+		return
+	}
+	if err := civControl.powerOffRadio(); err != nil {
+		log.Error("can't power off the radio: ", err)
+	}
+}
+
+// Errors which can't be fixed by reconnecting make the app exit.
+func reportFatalError(err error) {
+	select {
+	case fatalErrChan <- err:
+	default:
 	}
 }
 
@@ -103,6 +217,7 @@ func main() {
 	parseArgs()
 	log.Init()
 	log.Print(getAboutStr())
+	logConfig()
 
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, os.Interrupt, syscall.SIGTERM)
@@ -149,6 +264,12 @@ exit:
 	}
 
 	rigctld.deinit()
+	civCmdSrv.deinit()
+	tts.deinit()
+	statusJSON.deinit()
+	extPTT.deinit()
+	sessionLog.deinit()
+	stateCSV.deinit()
 	serialTCPSrv.deinit()
 	runCmdRunner.stop()
 	serialCmdRunner.stop()
@@ -6,6 +6,9 @@ import (
 	"time"
 )
 
+// Upper limits of the retransmit request gap size histogram buckets, the last bucket has no limit.
+var retransmitGapBuckets = []int{1, 2, 4, 8, 16}
+
 type netstatStruct struct {
 	toRadioBytes   int
 	toRadioPkts    int
@@ -17,6 +20,22 @@ type netstatStruct struct {
 	lastLostReport       time.Time
 	retransmits          int
 	lastRetransmitReport time.Time
+
+	// Totals since the connection was started.
+	totalLostPkts    int
+	totalRetransmits int
+
+	// Retransmit request gap size histogram and largest loss burst since the connection was started.
+	retransmitGaps   [6]int
+	largestGap       int
+	largestLossBurst int
+
+	// RX audio jitter buffer stats.
+	audioBufDepth           time.Duration
+	audioUnderruns          int
+	lastAudioUnderrunReport time.Time
+	audioOverruns           int
+	lastAudioOverrunReport  time.Time
 }
 
 var netstat netstatStruct
@@ -50,6 +69,52 @@ func (b *netstatStruct) reportLoss(pkts int) {
 
 	b.lastLostReport = time.Now()
 	b.lostPkts += pkts
+	b.totalLostPkts += pkts
+	if pkts > b.largestLossBurst {
+		b.largestLossBurst = pkts
+	}
+}
+
+// Call this function when a retransmit is requested for a gap of the given packet count.
+func (b *netstatStruct) reportRetransmitGap(pkts int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	i := 0
+	for i < len(retransmitGapBuckets) && pkts > retransmitGapBuckets[i] {
+		i++
+	}
+	b.retransmitGaps[i]++
+	if pkts > b.largestGap {
+		b.largestGap = pkts
+	}
+}
+
+func (b *netstatStruct) getRetransmitGaps() (gaps [6]int, largestGap, largestLossBurst int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	return b.retransmitGaps, b.largestGap, b.largestLossBurst
+}
+
+// Returns the retransmit request gap size histogram and the largest gap and loss burst.
+func (b *netstatStruct) getRetransmitBreakdown() string {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	str := "retransmit request gaps:"
+	from := 1
+	for i, to := range retransmitGapBuckets {
+		if from == to {
+			str += fmt.Sprint(" ", to, ": ", b.retransmitGaps[i])
+		} else {
+			str += fmt.Sprint(" ", from, "-", to, ": ", b.retransmitGaps[i])
+		}
+		from = to + 1
+	}
+	str += fmt.Sprint(" ", from, "+: ", b.retransmitGaps[len(retransmitGapBuckets)])
+	str += fmt.Sprint(", largest gap: ", b.largestGap, ", largest loss burst: ", b.largestLossBurst)
+	return str
 }
 
 func (b *netstatStruct) reportRetransmit(pkts int) {
@@ -58,6 +123,59 @@ func (b *netstatStruct) reportRetransmit(pkts int) {
 
 	b.lastRetransmitReport = time.Now()
 	b.retransmits += pkts
+	b.totalRetransmits += pkts
+}
+
+func (b *netstatStruct) getTotals() (lost int, retransmits int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	return b.totalLostPkts, b.totalRetransmits
+}
+
+func (b *netstatStruct) reportAudioBufDepth(depth time.Duration) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	b.audioBufDepth = depth
+}
+
+// Call this function when the RX audio jitter buffer runs dry.
+func (b *netstatStruct) reportAudioUnderrun() {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	b.lastAudioUnderrunReport = time.Now()
+	b.audioUnderruns++
+}
+
+// Call this function when RX audio is dropped because the play buffer is full.
+func (b *netstatStruct) reportAudioOverrun() {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	b.lastAudioOverrunReport = time.Now()
+	b.audioOverruns++
+}
+
+func (b *netstatStruct) getAudioBufStats() (depth time.Duration, underruns int, overruns int) {
+	netstatMutex.Lock()
+	defer netstatMutex.Unlock()
+
+	depth = b.audioBufDepth
+
+	underruns = b.audioUnderruns
+	if time.Since(b.lastAudioUnderrunReport).Seconds() >= 60 {
+		b.audioUnderruns = 0
+		b.lastAudioUnderrunReport = time.Now()
+	}
+
+	overruns = b.audioOverruns
+	if time.Since(b.lastAudioOverrunReport).Seconds() >= 60 {
+		b.audioOverruns = 0
+		b.lastAudioOverrunReport = time.Now()
+	}
+	return
 }
 
 func (b *netstatStruct) get() (toRadioBytesPerSec, fromRadioBytesPerSec int, lost int, retransmits int) {
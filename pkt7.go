@@ -61,7 +61,9 @@ func (p *pkt7Type) handle(s *streamCommon, r []byte) error {
 
 			if s.name == "control" { // Only measure latency on the control stream.
 				// Only measure latency after the timeout has been initialized, so the auth is already done.
-				p.latency += time.Since(p.lastSendAt)
+				rtt := time.Since(p.lastSendAt)
+				sessionStats.reportRTT(rtt)
+				p.latency += rtt
 				p.latency /= 2
 				statusLog.reportRTTLatency(p.latency)
 
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// The radio is operated over the network, so its display only wastes battery. If power saving is
+// enabled, the LCD backlight is turned down and the scope is turned off on connect, and both are restored
+// on disconnect and on exit.
+
+// LCD backlight menu item, level is 0000-0255 (BCD). Menu item numbers differ between radios, so the
+// backlight is only changed if the radio answers the query with a valid level.
+var lcdBacklightCmd = []byte{0x1a, 0x05, 0x01, 0x81}
+var scopeOnOffCmd = []byte{0x27, 0x10}
+
+type radioPowerSaveStruct struct {
+	mutex sync.Mutex
+
+	// The original settings are read on connect, and kept until they are restored. If restoring failed
+	// (for example because the connection was lost), then the radio is still in power saving state on
+	// reconnect, so the settings are not read again.
+	saved     bool
+	backlight []byte // nil if the radio doesn't support the backlight menu item
+	scopeOn   bool
+}
+
+var radioPowerSave radioPowerSaveStruct
+
+// sends a raw command and returns the data after the command sequence, the answer should start with the
+// whole sent command (including the subcmd)
+func (p *radioPowerSaveStruct) query(cmd []byte) ([]byte, error) {
+	d, err := civControl.sendRawCIV(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if d[4] == NG {
+		return nil, fmt.Errorf("radio refused % x", cmd)
+	}
+	if len(d) < 4+len(cmd)+2 || !bytes.Equal(d[4:4+len(cmd)], cmd) {
+		return nil, fmt.Errorf("unexpected answer for % x", cmd)
+	}
+	return d[4+len(cmd) : len(d)-1], nil
+}
+
+func (p *radioPowerSaveStruct) set(cmd []byte, data []byte) error {
+	d, err := civControl.sendRawCIV(append(append([]byte{}, cmd...), data...))
+	if err != nil {
+		return err
+	}
+	if d[4] != OK {
+		return fmt.Errorf("radio refused % x", cmd)
+	}
+	return nil
+}
+
+// the backlight level is 0000-0255 in BCD
+func isValidBacklightLevel(d []byte) bool {
+	if len(d) != 2 {
+		return false
+	}
+	var v int
+	for _, b := range d {
+		if b>>4 > 9 || b&0x0f > 9 {
+			return false
+		}
+		v = v*100 + int(b>>4)*10 + int(b&0x0f)
+	}
+	return v <= 255
+}
+
+func (p *radioPowerSaveStruct) save() error {
+	p.backlight = nil
+	backlight, err := p.query(lcdBacklightCmd)
+	if err == nil && !isValidBacklightLevel(backlight) {
+		err = errors.New("invalid backlight level")
+	}
+	if err != nil {
+		log.Error("the radio's backlight can't be changed: ", err)
+	} else {
+		p.backlight = backlight
+	}
+
+	scope, err := p.query(scopeOnOffCmd)
+	if err != nil {
+		return err
+	}
+	p.scopeOn = scope[0] != 0
+	p.saved = true
+	return nil
+}
+
+// Called after the CI-V connection is up, blocks until the radio answers.
+func (p *radioPowerSaveStruct) applyIfNeeded() {
+	if !radioPowerSaveEnabled {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.saved {
+		if err := p.save(); err != nil {
+			log.Error("can't read the radio's display settings: ", err)
+			return
+		}
+	}
+
+	if p.backlight != nil {
+		if err := p.set(lcdBacklightCmd, []byte{0x00, 0x00}); err != nil {
+			log.Error("can't turn down the backlight: ", err)
+		}
+	}
+	if err := p.set(scopeOnOffCmd, []byte{0x00}); err != nil {
+		log.Error("can't turn off the scope: ", err)
+	}
+	log.Print("radio power saving is on")
+}
+
+// Called before disconnecting, blocks until the radio answers.
+func (p *radioPowerSaveStruct) restoreIfNeeded() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.saved {
+		return
+	}
+
+	if p.backlight != nil {
+		if err := p.set(lcdBacklightCmd, p.backlight); err != nil {
+			log.Error("can't restore the backlight: ", err)
+			return
+		}
+	}
+	var scope byte
+	if p.scopeOn {
+		scope = 0x01
+	}
+	if err := p.set(scopeOnOffCmd, []byte{scope}); err != nil {
+		log.Error("can't restore the scope: ", err)
+		return
+	}
+	p.saved = false
+	log.Print("radio display settings restored")
+}
@@ -142,7 +142,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 			_ = s.sendReplyCode(rigctldInvalidParam)
 			return
 		}
-		err = civControl.setMainVFOFreq(uint(f))
+		err = civControl.setMainVFOFreq(uint64(f))
 		if err != nil {
 			_ = s.sendReplyCode(rigctldInvalidParam)
 			return
@@ -156,6 +156,10 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		if civControl.state.dataMode {
 			mode = "PKT"
 		}
+		if civControl.state.operatingModeIdx < 0 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
 		mode += civOperatingModes[civControl.state.operatingModeIdx].name
 
 		// This can be queried with a CIV command for accurate values by the way.
@@ -238,9 +242,14 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
 	case cmdSplit[0] == "V", cmdSplit[0] == "\\set_vfo":
-		if cmdSplit[1] == "VFOB" {
+		switch cmdSplit[1] {
+		case "VFOB":
 			err = civControl.setVFO(1)
-		} else {
+		case "MEM":
+			err = civControl.setMemoryMode()
+		case "VFO":
+			err = civControl.setVFOMode()
+		default:
 			err = civControl.setVFO(0)
 		}
 		if err != nil {
@@ -248,6 +257,32 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
+	case cmd == "e", cmd == "\\get_mem":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		if civControl.state.memoryChannel < 0 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = s.send(civControl.state.memoryChannel, "\n")
+	case cmdSplit[0] == "E", cmdSplit[0] == "\\set_mem":
+		if len(cmdSplit) < 2 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		var ch int
+		ch, err = strconv.Atoi(cmdSplit[1])
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = civControl.setMemoryChannel(ch)
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = s.sendReplyCode(rigctldNoError)
 	case cmd == "s", cmd == "\\get_split_vfo":
 		civControl.state.mutex.Lock()
 		defer civControl.state.mutex.Unlock()
@@ -290,7 +325,7 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 			_ = s.sendReplyCode(rigctldInvalidParam)
 			return
 		}
-		err = civControl.setSubVFOFreq(uint(f))
+		err = civControl.setSubVFOFreq(uint64(f))
 		if err != nil {
 			_ = s.sendReplyCode(rigctldInvalidParam)
 			return
@@ -304,6 +339,10 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		if civControl.state.subDataMode {
 			mode = "PKT"
 		}
+		if civControl.state.subOperatingModeIdx < 0 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
 		mode += civOperatingModes[civControl.state.subOperatingModeIdx].name
 
 		// This can be queried with a CIV command for accurate values by the way.
@@ -354,6 +393,56 @@ func (s *rigctldStruct) processCmd(cmd string) (close bool, err error) {
 		} else {
 			_ = s.sendReplyCode(rigctldNoError)
 		}
+	case cmd == "z", cmd == "\\get_xit":
+		civControl.state.mutex.Lock()
+		defer civControl.state.mutex.Unlock()
+
+		err = s.send(civControl.state.xitOffset, "\n")
+	case cmdSplit[0] == "Z", cmdSplit[0] == "\\set_xit":
+		if len(cmdSplit) < 2 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		var offset int
+		offset, err = strconv.Atoi(cmdSplit[1])
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = civControl.setXIT(offset)
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = s.sendReplyCode(rigctldNoError)
+	case cmdSplit[0] == "\\send_dtmf":
+		if len(cmdSplit) < 2 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = sendDTMF(cmdSplit[1])
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = s.sendReplyCode(rigctldNoError)
+	case cmdSplit[0] == "\\send_voice_mem":
+		if len(cmdSplit) < 2 {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		var slot uint64
+		slot, err = strconv.ParseUint(cmdSplit[1], 10, 8)
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = civControl.playVoiceMemory(byte(slot))
+		if err != nil {
+			_ = s.sendReplyCode(rigctldInvalidParam)
+			return
+		}
+		err = s.sendReplyCode(rigctldNoError)
 	case cmd == "v": // Ignore this command.
 		_ = s.sendReplyCode(rigctldUnsupportedCmd)
 		return
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Optional AGC of the received audio played on the default sound device, so loud and weak stations
+// are played at a comparable volume. Audio sent to the virtual sound card is not changed, as it's
+// usually decoded by digital mode apps which have their own level handling.
+
+const rxAudioAGCTarget = 0.25 // -12dBFS
+const rxAudioAGCMaxGain = 30  // ~30dB, so the noise floor between transmissions is not boosted too much
+
+// They can be set with command line arguments.
+var rxAudioAGCAttack = 10 * time.Millisecond
+var rxAudioAGCRelease = 500 * time.Millisecond
+
+type rxAudioAGCStruct struct {
+	initialized bool
+
+	attackCoeff  float64
+	releaseCoeff float64
+	envelope     float64
+}
+
+var rxAudioAGC rxAudioAGCStruct
+
+func (p *rxAudioAGCStruct) init() {
+	fs := float64(audioSampleRate)
+	p.attackCoeff = math.Exp(-1 / (rxAudioAGCAttack.Seconds() * fs))
+	p.releaseCoeff = math.Exp(-1 / (rxAudioAGCRelease.Seconds() * fs))
+	p.envelope = rxAudioAGCTarget
+	p.initialized = true
+}
+
+// Expects samples in the -1..1 range.
+func (p *rxAudioAGCStruct) gain(x float64) float64 {
+	level := math.Abs(x)
+	if level > p.envelope {
+		p.envelope = p.attackCoeff*p.envelope + (1-p.attackCoeff)*level
+	} else {
+		p.envelope = p.releaseCoeff*p.envelope + (1-p.releaseCoeff)*level
+	}
+
+	if p.envelope*rxAudioAGCMaxGain < rxAudioAGCTarget {
+		return x * rxAudioAGCMaxGain
+	}
+	return x * rxAudioAGCTarget / p.envelope
+}
+
+// Processes the given s16le PCM data in place.
+func (p *rxAudioAGCStruct) process(pcm []byte) {
+	if !p.initialized {
+		p.init()
+	}
+
+	for i := 0; i+1 < len(pcm); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(pcm[i:i+2]))) / 32768
+		x = p.gain(x)
+		x = math.Max(-32768, math.Min(x*32768, 32767))
+		binary.LittleEndian.PutUint16(pcm[i:i+2], uint16(int16(x)))
+	}
+}
@@ -226,6 +226,17 @@ func (s *seqBuf) add(seq seqNum, data []byte) error {
 	return nil
 }
 
+// Returns the summed data length of all entries waiting in the seqbuf.
+func (s *seqBuf) getDataLength() (l int) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, e := range s.entries {
+		l += len(e.data)
+	}
+	return
+}
+
 func (s *seqBuf) checkLockTimeout() (timeout bool, shouldRetryIn time.Duration) {
 	timeSinceLastInvalidSeq := time.Since(s.lockedAt)
 	lockDuration := s.length
@@ -100,12 +100,16 @@ func (s *serialPortStruct) initIfNeeded(devName string) (err error) {
 	if err != nil {
 		return err
 	}
-	s.symlink = "/tmp/kappanhang-" + devName + ".pty"
+	s.symlink = serialDevicePath
+	if s.symlink == "" {
+		s.symlink = "/tmp/kappanhang-" + devName + ".pty"
+	}
 	_ = os.Remove(s.symlink)
 	if err := os.Symlink(n, s.symlink); err != nil {
 		return err
 	}
 	log.Print("opened ", n, " as ", s.symlink)
+	log.Print("virtual serial port is ready: ", s.symlink)
 
 	s.write = make(chan []byte)
 	s.read = make(chan []byte)
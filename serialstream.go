@@ -7,6 +7,9 @@ import (
 )
 
 const maxSerialFrameLength = 80 // Max. frame length according to Hamlib.
+// Partial frames from the radio are dropped above this length. It's larger than maxSerialFrameLength,
+// as some frames (like scope waveform data) can be longer.
+const maxRadioFrameLength = 1024
 const serialRxSeqBufLength = 100 * time.Millisecond
 
 type serialStream struct {
@@ -26,6 +29,11 @@ type serialStream struct {
 		frameTimeout *time.Timer
 	}
 
+	// Data received from the radio which does not contain a complete frame yet.
+	readFromRadio struct {
+		buf bytes.Buffer
+	}
+
 	deinitNeededChan   chan bool
 	deinitFinishedChan chan bool
 }
@@ -91,20 +99,88 @@ func (s *serialStream) handleRxSeqBufEntry(e seqBufEntry) {
 		return
 	}
 
-	e.data = e.data[21:]
+	s.gotDataFromRadio(e.data[21:])
+}
+
+// A packet from the radio may contain a partial frame or multiple frames, so the data is collected
+// until a frame end byte is received, and complete frames are processed one by one.
+func (s *serialStream) gotDataFromRadio(d []byte) {
+	s.readFromRadio.buf.Write(d)
 
+	for {
+		b := s.readFromRadio.buf.Bytes()
+
+		// Cut until we find the frame start bytes.
+		start := bytes.Index(b, []byte{0xfe, 0xfe})
+		if start < 0 {
+			// A trailing start byte is kept, as it may be the first of the next frame's start bytes.
+			if len(b) > 0 && b[len(b)-1] == 0xfe {
+				s.readFromRadio.buf.Next(len(b) - 1)
+			} else {
+				s.readFromRadio.buf.Reset()
+			}
+			return
+		}
+		// Skipping extra preamble bytes.
+		for start+2 < len(b) && b[start+2] == 0xfe {
+			start++
+		}
+		s.readFromRadio.buf.Next(start)
+		b = b[start:]
+
+		end := -1
+		nextStart := -1
+		for i := 2; i < len(b); i++ {
+			if b[i] == 0xfc || b[i] == 0xfd {
+				end = i
+				break
+			}
+			if b[i] == 0xfe && i+1 < len(b) && b[i+1] == 0xfe {
+				nextStart = i
+				break
+			}
+		}
+		if nextStart >= 0 {
+			// A new frame started before the end of the current one, so the partial frame is dropped.
+			log.Debug("dropping incomplete frame from radio")
+			s.readFromRadio.buf.Next(nextStart)
+			continue
+		}
+		if end < 0 {
+			if len(b) >= maxRadioFrameLength {
+				log.Error("dropping too long partial frame from radio (", len(b), " bytes)")
+				s.readFromRadio.buf.Next(2)
+				continue
+			}
+			return // Waiting for the rest of the frame.
+		}
+
+		frame := make([]byte, end+1)
+		copy(frame, b)
+		s.readFromRadio.buf.Next(end + 1)
+
+		// 0xfc is sent by the radio when it detects a collision on the bus, the frame is dropped.
+		if frame[end] == 0xfc {
+			log.Debug("dropping collided frame from radio")
+			continue
+		}
+		s.handleFrameFromRadio(frame)
+	}
+}
+
+func (s *serialStream) handleFrameFromRadio(d []byte) {
 	// decode the received CI-V data packet
 	// if it fails return directly to the main polling loop, without sending it on to the serial &/or network channels
 
-	if !civControl.decode(e.data) {
+	if !civControl.decode(d) {
 		return
 	}
 
 	if serialPort.write != nil {
-		serialPort.write <- e.data
+		serialPort.write <- d
 	}
 	if serialTCPSrv.isClientConnected() {
-		serialTCPSrv.toClient <- e.data
+		serialTCPSrv.sendToClients(d)
 	}
 }
 
@@ -247,16 +323,23 @@ func (s *serialStream) init(devName string) error {
 	s.deinitNeededChan = make(chan bool)
 	s.deinitFinishedChan = make(chan bool)
 
+	s.readFromRadio.buf.Reset()
+
 	s.readFromSerialPort.frameTimeout = time.NewTimer(0)
 	<-s.readFromSerialPort.frameTimeout.C
 
 	civControl.deinit()
-	civControl = civControlStruct{}
+	civControl.reset()
+	// The loop is needed for receiving the answer of the self test.
+	go s.loop()
+	if err := civControl.selfTest(s); err != nil {
+		return err
+	}
 	if err := civControl.init(s); err != nil {
 		return err
 	}
 
-	go s.loop()
+	go radioPowerSave.applyIfNeeded()
 	return nil
 }
 
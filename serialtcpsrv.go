@@ -7,21 +7,42 @@ import (
 	"sync"
 )
 
+// Number of radio data packets buffered for each client. A client which can't keep up with the
+// radio is disconnected, so it won't hold up the other clients.
+const serialTCPClientBufferLen = 64
+
+type serialTCPClient struct {
+	conn net.Conn
+
+	toClient chan []byte
+
+	closeChan chan bool
+	closeOnce sync.Once
+	doneChan  chan bool
+}
+
+func (c *serialTCPClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.closeChan)
+		c.conn.Close()
+	})
+}
+
 type serialTCPSrvStruct struct {
 	listener net.Listener
-	client   net.Conn
 
-	fromClient chan []byte
-	toClient   chan []byte
+	// All connected clients receive the data coming from the radio, but only the writer client
+	// can send data to the radio. The first connected client is the writer, when it disconnects
+	// the next oldest client takes over.
+	clients []*serialTCPClient
+	writer  *serialTCPClient
 
-	clientLoopDeinitNeededChan   chan bool
-	clientLoopDeinitFinishedChan chan bool
+	fromClient chan []byte
 
 	deinitNeededChan   chan bool
 	deinitFinishedChan chan bool
 
-	clientConnected bool
-	mutex           sync.Mutex
+	mutex sync.Mutex
 }
 
 var serialTCPSrv serialTCPSrvStruct
@@ -30,93 +51,148 @@ func (s *serialTCPSrvStruct) isClientConnected() bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	return s.clientConnected
+	return len(s.clients) > 0
+}
+
+// Sends the data to all connected clients. This never blocks, slow clients get disconnected.
+func (s *serialTCPSrvStruct) sendToClients(b []byte) {
+	s.mutex.Lock()
+	clients := make([]*serialTCPClient, len(s.clients))
+	copy(clients, s.clients)
+	s.mutex.Unlock()
+
+	for _, c := range clients {
+		select {
+		case c.toClient <- b:
+		case <-c.closeChan:
+		default:
+			log.Error("client ", c.conn.RemoteAddr().String(), " is too slow, disconnecting")
+			c.close()
+		}
+	}
+}
+
+func (s *serialTCPSrvStruct) isWriter(c *serialTCPClient) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.writer == c
 }
 
-func (s *serialTCPSrvStruct) writeLoop(writeLoopDeinitNeededChan, writeLoopDeinitFinishedChan chan bool,
-	errChan chan error) {
+func (s *serialTCPSrvStruct) addClient(c *serialTCPClient) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
+	s.clients = append(s.clients, c)
+	if s.writer == nil {
+		s.writer = c
+	}
+}
+
+func (s *serialTCPSrvStruct) removeClient(c *serialTCPClient) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := range s.clients {
+		if s.clients[i] == c {
+			s.clients = append(s.clients[:i], s.clients[i+1:]...)
+			break
+		}
+	}
+	if s.writer == c {
+		s.writer = nil
+		if len(s.clients) > 0 {
+			s.writer = s.clients[0]
+			log.Print("client ", s.writer.conn.RemoteAddr().String(), " is now the writer")
+		}
+	}
+}
+
+func (s *serialTCPSrvStruct) writeLoop(c *serialTCPClient) {
 	var b []byte
 	for {
 		select {
-		case b = <-s.toClient:
-		case <-writeLoopDeinitNeededChan:
-			writeLoopDeinitFinishedChan <- true
+		case b = <-c.toClient:
+		case <-c.closeChan:
 			return
 		}
 
 		for len(b) > 0 {
-			written, err := s.client.Write(b)
+			written, err := c.conn.Write(b)
 			if err != nil {
-				errChan <- err
-				break
+				// Closing the connection makes the client loop exit.
+				c.close()
+				return
 			}
 			b = b[written:]
 		}
 	}
 }
 
-func (s *serialTCPSrvStruct) disconnectClient() {
-	if s.client != nil {
-		s.client.Close()
+func (s *serialTCPSrvStruct) disconnectClients() {
+	s.mutex.Lock()
+	clients := make([]*serialTCPClient, len(s.clients))
+	copy(clients, s.clients)
+	s.mutex.Unlock()
+
+	for _, c := range clients {
+		c.close()
+		<-c.doneChan
 	}
 }
 
-func (s *serialTCPSrvStruct) deinitClient() {
-	if s.clientLoopDeinitNeededChan != nil {
-		s.clientLoopDeinitNeededChan <- true
-		<-s.clientLoopDeinitFinishedChan
+// Half-open connections (for example when the client app crashed) are detected by TCP keepalive
+// probes, so they don't hold the port until the next client connects.
+func (s *serialTCPSrvStruct) setKeepAlive(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
 
-		s.clientLoopDeinitNeededChan = nil
-		s.clientLoopDeinitFinishedChan = nil
+	if serialTCPKeepAlive == 0 {
+		_ = tcpConn.SetKeepAlive(false)
+		return
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		log.Error("can't enable keepalive: ", err)
+		return
+	}
+	if err := tcpConn.SetKeepAlivePeriod(serialTCPKeepAlive); err != nil {
+		log.Error("can't set keepalive period: ", err)
 	}
 }
 
-func (s *serialTCPSrvStruct) clientLoop() {
-	s.mutex.Lock()
-	s.clientConnected = true
-	s.mutex.Unlock()
-
-	defer func() {
-		s.mutex.Lock()
-		s.clientConnected = false
-		s.mutex.Unlock()
-	}()
-
-	log.Print("client ", s.client.RemoteAddr().String(), " connected")
+func (s *serialTCPSrvStruct) clientLoop(c *serialTCPClient) {
+	if s.isWriter(c) {
+		log.Print("client ", c.conn.RemoteAddr().String(), " connected")
+	} else {
+		log.Print("client ", c.conn.RemoteAddr().String(), " connected (read only)")
+	}
 
-	writeLoopDeinitNeededChan := make(chan bool)
-	writeLoopDeinitFinishedChan := make(chan bool)
-	writeErrChan := make(chan error)
-	go s.writeLoop(writeLoopDeinitNeededChan, writeLoopDeinitFinishedChan, writeErrChan)
+	go s.writeLoop(c)
 
 	defer func() {
-		s.client.Close()
-		log.Print("client ", s.client.RemoteAddr().String(), " disconnected")
-
-		writeLoopDeinitNeededChan <- true
-		<-writeLoopDeinitFinishedChan
-
-		<-s.clientLoopDeinitNeededChan
-		s.clientLoopDeinitFinishedChan <- true
+		c.close()
+		s.removeClient(c)
+		log.Print("client ", c.conn.RemoteAddr().String(), " disconnected")
+		c.doneChan <- true
 	}()
 
 	for {
 		b := make([]byte, maxSerialFrameLength)
-		n, err := s.client.Read(b)
+		n, err := c.conn.Read(b)
 		if err != nil {
-			break
+			return
+		}
+
+		// Data from read only clients is dropped.
+		if !s.isWriter(c) {
+			continue
 		}
 
 		select {
 		case s.fromClient <- b[:n]:
-		case <-writeErrChan:
-			return
-		case <-s.clientLoopDeinitNeededChan:
-			writeLoopDeinitNeededChan <- true
-			<-writeLoopDeinitFinishedChan
-
-			s.clientLoopDeinitFinishedChan <- true
+		case <-c.closeChan:
 			return
 		}
 	}
@@ -125,14 +201,9 @@ func (s *serialTCPSrvStruct) clientLoop() {
 func (s *serialTCPSrvStruct) loop() {
 	for {
 		newClient, err := s.listener.Accept()
-
-		s.disconnectClient()
-		s.deinitClient()
-
-		s.clientLoopDeinitNeededChan = make(chan bool)
-		s.clientLoopDeinitFinishedChan = make(chan bool)
-
 		if err != nil {
+			s.disconnectClients()
+
 			if err != io.EOF {
 				reportError(err)
 			}
@@ -141,9 +212,18 @@ func (s *serialTCPSrvStruct) loop() {
 			return
 		}
 
-		s.client = newClient
+		s.setKeepAlive(newClient)
 
-		go s.clientLoop()
+		c := &serialTCPClient{
+			conn:      newClient,
+			toClient:  make(chan []byte, serialTCPClientBufferLen),
+			closeChan: make(chan bool),
+			doneChan:  make(chan bool, 1),
+		}
+		// Adding the client here, and not in the client goroutine, so disconnectClients() (which
+		// is also called from this goroutine) always sees all clients.
+		s.addClient(c)
+		go s.clientLoop(c)
 	}
 }
 
@@ -170,7 +250,6 @@ func (s *serialTCPSrvStruct) initIfNeeded() (err error) {
 	log.Print("exposing serial port on tcp port ", serialTCPPort)
 
 	s.fromClient = make(chan []byte)
-	s.toClient = make(chan []byte)
 
 	s.deinitNeededChan = make(chan bool)
 	s.deinitFinishedChan = make(chan bool)
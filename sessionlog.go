@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const sessionLogLongTXDuration = 30 * time.Second
+
+// If the --session-log option is set, then QSO-relevant events (band changes, long transmits, stored
+// spots, connection summaries) are appended to the given file. Lines are buffered and written to disk
+// periodically, so the log survives a crash or a power loss of a battery powered station, except for the
+// last interval.
+type sessionLogStruct struct {
+	mutex  sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+
+	band string
+
+	deinitNeededChan   chan bool
+	deinitFinishedChan chan bool
+}
+
+var sessionLog sessionLogStruct
+
+func (s *sessionLogStruct) event(a ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.writer == nil {
+		return
+	}
+	_, _ = fmt.Fprintln(s.writer, time.Now().Format(time.RFC3339), fmt.Sprint(a...))
+}
+
+func (s *sessionLogStruct) reportBand(name string) {
+	s.mutex.Lock()
+	prevBand := s.band
+	s.band = name
+	s.mutex.Unlock()
+
+	if name != "" && name != prevBand {
+		s.event("band ", name)
+	}
+}
+
+func (s *sessionLogStruct) reportTXEnd(d time.Duration) {
+	if d >= sessionLogLongTXDuration {
+		s.event("long transmit ", d.Round(time.Second))
+	}
+}
+
+func (s *sessionLogStruct) flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.writer == nil || s.writer.Buffered() == 0 {
+		return
+	}
+	if err := s.writer.Flush(); err != nil {
+		log.Error("can't write session log: ", err)
+		return
+	}
+	if err := s.file.Sync(); err != nil {
+		log.Error("can't sync session log: ", err)
+	}
+}
+
+func (s *sessionLogStruct) loop() {
+	ticker := time.NewTicker(autoSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.deinitNeededChan:
+			s.deinitFinishedChan <- true
+			return
+		}
+	}
+}
+
+func (s *sessionLogStruct) initIfNeeded() error {
+	if s.deinitNeededChan != nil || sessionLogFile == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(sessionLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.file = f
+	s.writer = bufio.NewWriter(f)
+	s.mutex.Unlock()
+
+	log.Print("writing session log to ", sessionLogFile, ", saved every ", autoSaveInterval)
+
+	s.deinitNeededChan = make(chan bool)
+	s.deinitFinishedChan = make(chan bool)
+	go s.loop()
+	return nil
+}
+
+func (s *sessionLogStruct) deinit() {
+	if s.deinitNeededChan != nil {
+		s.deinitNeededChan <- true
+		<-s.deinitFinishedChan
+		s.deinitNeededChan = nil
+	}
+	s.flush()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+		s.writer = nil
+	}
+}
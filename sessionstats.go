@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statistics of a connection to the radio, which are logged as a summary when the connection ends.
+type sessionStatsStruct struct {
+	mutex sync.Mutex
+
+	startedAt   time.Time
+	txStartedAt time.Time
+	txTime      time.Duration
+
+	rttSum   time.Duration
+	rttCount int
+	rttPeak  time.Duration
+
+	bands    []string
+	bandSeen map[string]bool
+}
+
+var sessionStats sessionStatsStruct
+
+func (s *sessionStatsStruct) start() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	*s = sessionStatsStruct{
+		startedAt: time.Now(),
+		bandSeen:  make(map[string]bool),
+	}
+}
+
+func (s *sessionStatsStruct) reportRTT(rtt time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.rttSum += rtt
+	s.rttCount++
+	if rtt > s.rttPeak {
+		s.rttPeak = rtt
+	}
+}
+
+// Call this function when PTT or tune changes.
+func (s *sessionStatsStruct) reportTX(tx bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if tx && s.txStartedAt.IsZero() {
+		s.txStartedAt = time.Now()
+	} else if !tx && !s.txStartedAt.IsZero() {
+		d := time.Since(s.txStartedAt)
+		s.txTime += d
+		s.txStartedAt = time.Time{}
+		sessionLog.reportTXEnd(d)
+	}
+}
+
+func (s *sessionStatsStruct) reportBand(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if name == "" || s.bandSeen == nil || s.bandSeen[name] {
+		return
+	}
+	s.bandSeen[name] = true
+	s.bands = append(s.bands, name)
+}
+
+func (s *sessionStatsStruct) print() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.startedAt.IsZero() {
+		return
+	}
+
+	txTime := s.txTime
+	if !s.txStartedAt.IsZero() {
+		txTime += time.Since(s.txStartedAt)
+	}
+	var rttAvg time.Duration
+	if s.rttCount > 0 {
+		rttAvg = s.rttSum / time.Duration(s.rttCount)
+	}
+	bands := "none"
+	if len(s.bands) > 0 {
+		bands = strings.Join(s.bands, " ")
+	}
+	lost, retransmits := netstat.getTotals()
+
+	summary := fmt.Sprint("session summary: uptime ", time.Since(s.startedAt).Round(time.Second),
+		", tx time ", txTime.Round(time.Second),
+		", rtt avg ", rttAvg.Milliseconds(), "ms peak ", s.rttPeak.Milliseconds(), "ms",
+		", lost pkts ", lost, ", retransmitted pkts ", retransmits,
+		", bands ", bands)
+	log.Print(summary)
+	sessionLog.event(summary)
+
+	s.startedAt = time.Time{}
+}
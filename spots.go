@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spots are stored in an append-only text file, one spot per line in the following format:
+//
+//	<freq in Hz> <mode> <time in RFC3339>
+//
+// The file is read again every time we cycle through the spots, so it can also be edited by hand.
+type spot struct {
+	freq uint64
+	mode string
+}
+
+type spotsStruct struct {
+	// The index of the last spot we tuned to.
+	idx int
+}
+
+var spots spotsStruct
+
+func (s *spotsStruct) store() error {
+	civControl.state.mutex.Lock()
+	freq := civControl.state.freq
+	var mode string
+	if civControl.state.operatingModeIdx >= 0 {
+		mode = civOperatingModes[civControl.state.operatingModeIdx].name
+	}
+	civControl.state.mutex.Unlock()
+
+	if freq == 0 {
+		return errors.New("frequency is not known yet")
+	}
+	if mode == "" {
+		mode = "-"
+	}
+
+	f, err := os.OpenFile(spotsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%d %s %s\n", freq, mode, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	// Spots are written to disk right away, so they survive a power loss.
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	log.Print("stored spot ", freq, " ", mode, " to ", spotsFile)
+	sessionLog.event("spot ", freq, " ", mode)
+	return nil
+}
+
+func (s *spotsStruct) load() (l []spot, err error) {
+	f, err := os.Open(spotsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		freq, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		var mode string
+		if len(fields) > 1 {
+			mode = fields[1]
+		}
+		l = append(l, spot{freq: freq, mode: mode})
+	}
+	return l, scanner.Err()
+}
+
+func (s *spotsStruct) getModeIdx(name string) int {
+	for i := range civOperatingModes {
+		if civOperatingModes[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Tunes to the next stored spot, and also changes the mode if it differs from the current one.
+func (s *spotsStruct) next() error {
+	l, err := s.load()
+	if err != nil {
+		return err
+	}
+	if len(l) == 0 {
+		return errors.New("no spots stored")
+	}
+
+	s.idx++
+	if s.idx >= len(l) {
+		s.idx = 0
+	}
+	sp := l[s.idx]
+
+	log.Print("tuning to spot ", s.idx+1, "/", len(l), ": ", sp.freq, " ", sp.mode)
+	if err := civControl.setMainVFOFreq(sp.freq); err != nil {
+		return err
+	}
+
+	modeIdx := s.getModeIdx(sp.mode)
+	if modeIdx < 0 || modeIdx == civControl.state.operatingModeIdx {
+		return nil
+	}
+	return civControl.setOperatingModeAndFilter(civOperatingModes[modeIdx].code,
+		civFilters[civControl.state.filterIdx].code)
+}
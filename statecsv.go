@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"sync"
+	"time"
+)
+
+// If the --state-csv option is set, then state changes are appended to the given CSV file, one row per
+// change with the following columns: time, field, old value, new value.
+type stateCSVStruct struct {
+	mutex  sync.Mutex
+	failed bool
+	file   *os.File
+	writer *csv.Writer
+	values map[string]string
+}
+
+var stateCSV stateCSVStruct
+
+func (s *stateCSVStruct) initIfNeeded() error {
+	if s.file != nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(stateCSVFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.writer = csv.NewWriter(f)
+	s.values = make(map[string]string)
+
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		return s.writer.Write([]string{"time", "field", "old", "new"})
+	}
+	return nil
+}
+
+func (s *stateCSVStruct) report(field, value string) {
+	if stateCSVFile == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.failed {
+		return
+	}
+	if err := s.initIfNeeded(); err != nil {
+		log.Error("can't open state csv: ", err)
+		s.failed = true
+		return
+	}
+
+	old, found := s.values[field]
+	if found && old == value {
+		return
+	}
+	s.values[field] = value
+
+	_ = s.writer.Write([]string{time.Now().Format("2006-01-02T15:04:05.000Z07:00"), field, old, value})
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		log.Error("can't write state csv: ", err)
+		s.failed = true
+	}
+}
+
+func (s *stateCSVStruct) deinit() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file != nil {
+		s.writer.Flush()
+		s.file.Close()
+		s.file = nil
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// If the --status-json option is set, then the status is periodically appended to the given file as
+// one JSON object per line. The file can be a named pipe read by a dashboard. The emit interval is
+// independent from the status bar refresh interval.
+type statusJSONData struct {
+	Time       string `json:"time"`
+	Freq       uint64 `json:"freq"`
+	SubFreq    uint64 `json:"sub_freq"`
+	Mode       string `json:"mode"`
+	DataMode   bool   `json:"data_mode"`
+	Filter     string `json:"filter"`
+	S          string `json:"s"`
+	OVF        bool   `json:"ovf"`
+	PTT        bool   `json:"ptt"`
+	Tune       bool   `json:"tune"`
+	SWR        string `json:"swr"`
+	Vd         string `json:"vd"`
+	TxPower    string `json:"tx_power"`
+	Split      string `json:"split"`
+	Preamp     string `json:"preamp"`
+	AGC        string `json:"agc"`
+	VFOBActive bool   `json:"vfo_b_active"`
+
+	Retransmits statusJSONRetransmits `json:"retransmits"`
+}
+
+// The gap size histogram uses the same buckets as the N hotkey: 1, 2, 3-4, 5-8, 9-16 and 17+ packets.
+type statusJSONRetransmits struct {
+	Gaps             [6]int `json:"gaps"`
+	LargestGap       int    `json:"largest_gap"`
+	LargestLossBurst int    `json:"largest_loss_burst"`
+}
+
+type statusJSONStruct struct {
+	deinitNeededChan   chan bool
+	deinitFinishedChan chan bool
+
+	file    *os.File
+	encoder *json.Encoder
+	last    statusJSONData
+	emitted bool
+}
+
+var statusJSON statusJSONStruct
+
+func (s *statusJSONStruct) getData() (d statusJSONData, ok bool) {
+	statusLog.mutex.Lock()
+	defer statusLog.mutex.Unlock()
+
+	if statusLog.data == nil {
+		return
+	}
+	d.Freq = statusLog.data.frequency
+	d.SubFreq = statusLog.data.subFrequency
+	d.Mode = statusLog.data.mode
+	d.DataMode = statusLog.data.dataMode != ""
+	d.Filter = statusLog.data.filter
+	d.S = statusLog.data.s
+	d.OVF = statusLog.data.ovf
+	d.PTT = statusLog.data.ptt
+	d.Tune = statusLog.data.tune
+	d.SWR = statusLog.data.swr
+	d.Vd = statusLog.data.vd
+	d.TxPower = statusLog.data.txPower
+	d.Split = statusLog.data.split
+	d.Preamp = statusLog.data.preamp
+	d.AGC = statusLog.data.agc
+	d.VFOBActive = statusLog.data.vfoBActive
+	d.Retransmits.Gaps, d.Retransmits.LargestGap, d.Retransmits.LargestLossBurst = netstat.getRetransmitGaps()
+	return d, true
+}
+
+func (s *statusJSONStruct) emit() {
+	if s.encoder == nil {
+		return
+	}
+	d, ok := s.getData()
+	if !ok {
+		return
+	}
+	// In delta mode only changed values are emitted. The time is not compared, as it always changes.
+	if statusJSONDelta && s.emitted && d == s.last {
+		return
+	}
+	s.last = d
+	s.emitted = true
+
+	d.Time = time.Now().Format("2006-01-02T15:04:05.000Z07:00")
+	if err := s.encoder.Encode(d); err != nil {
+		log.Error("can't write status json: ", err)
+	}
+}
+
+// Opening a named pipe for writing blocks until a reader opens it, so the file is opened in a separate
+// goroutine. The status is emitted only after the file is opened.
+func (s *statusJSONStruct) open(fileChan chan *os.File) {
+	f, err := os.OpenFile(statusJSONFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Error("can't open status json file: ", err)
+	}
+	fileChan <- f
+}
+
+func (s *statusJSONStruct) loop() {
+	fileChan := make(chan *os.File, 1)
+	go s.open(fileChan)
+
+	ticker := time.NewTicker(statusJSONInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case f := <-fileChan:
+			fileChan = nil
+			if f != nil {
+				s.file = f
+				s.encoder = json.NewEncoder(f)
+			}
+		case <-ticker.C:
+			s.emit()
+		case <-s.deinitNeededChan:
+			if fileChan != nil {
+				// The file is closed when a reader finally opens the pipe.
+				go func(fileChan chan *os.File) {
+					if f := <-fileChan; f != nil {
+						f.Close()
+					}
+				}(fileChan)
+			}
+			s.deinitFinishedChan <- true
+			return
+		}
+	}
+}
+
+func (s *statusJSONStruct) initIfNeeded() {
+	if s.deinitNeededChan != nil || statusJSONFile == "" {
+		return
+	}
+
+	log.Print("writing status json to ", statusJSONFile, " every ", statusJSONInterval)
+
+	s.deinitNeededChan = make(chan bool)
+	s.deinitFinishedChan = make(chan bool)
+	go s.loop()
+}
+
+func (s *statusJSONStruct) deinit() {
+	if s.deinitNeededChan != nil {
+		s.deinitNeededChan <- true
+		<-s.deinitFinishedChan
+		s.deinitNeededChan = nil
+	}
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+		s.encoder = nil
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +13,9 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
+// The remaining time is displayed this long before the max session duration is reached.
+const maxSessionWarning = 5 * time.Minute
+
 type statusLogData struct {
 	line1 string
 	line2 string
@@ -19,8 +23,18 @@ type statusLogData struct {
 
 	ptt          bool
 	tune         bool
-	frequency    uint
-	subFrequency uint
+	tuneDeadline time.Time
+	tunerEnabled bool
+	dualWatch    bool
+	dialLock     bool
+	memoryMode   bool
+	memoryCh     int // -1 if unknown
+	memoryName   string
+	bandEdge     string
+	watchedFreq  string
+	band         string
+	frequency    uint64
+	subFrequency uint64
 	mode         string
 	dataMode     string
 	filter       string
@@ -28,22 +42,54 @@ type statusLogData struct {
 	subDataMode  string
 	subFilter    string
 	preamp       string
+	attenuator   int
 	agc          string
 	vd           string
+	powerSource  string
 	txPower      string
 	rfGain       string
 	sql          string
+	sensitivity  string // combined RF gain and squelch
 	nr           string
 	nrEnabled    bool
+	nb           string
+	nbEnabled    bool
+	nbDepth      int
+	nbWidth      int
+	passband     string
+	filterWidth  string
+	scope        string
 	s            string
 	ovf          bool
+	ovfStale     bool // OVF polling is off or lagging
 	swr          string
+	alc          string
+	comp         string
 	ts           string
 	split        string
 	splitMode    splitMode
-
-	startTime time.Time
-	rttStr    string
+	duplexOffset uint64
+	vfoBActive   bool
+	ritEnabled   bool
+	xitEnabled   bool
+	xitOffset    string
+	dtmf         string
+	dtmfAt       time.Time
+	txInhibit    bool
+	txDelay      string
+	txDenied     string
+	txDeniedAt   time.Time
+	input        string
+
+	startTime  time.Time
+	sessionEnd bool // the max session duration is reached, quitting
+	rttStr     string
+	civRTTStr  string
+
+	unknownCIVFrames int
+	pendingCIVSets   int
+
+	serialDevice string
 
 	audioMonOn    bool
 	audioRecOn    bool
@@ -61,6 +107,12 @@ type statusLogStruct struct {
 		retransmitsColor *color.Color
 		lostColor        *color.Color
 		splitColor       *color.Color
+		ritXITColor      *color.Color
+		lockColor        *color.Color
+		bandEdgeColor    *color.Color
+		dataModeColor    *color.Color
+		watchedFreqColor *color.Color
+		splitTXColor     *color.Color
 
 		stateStr struct {
 			tx   string
@@ -72,7 +124,8 @@ type statusLogStruct struct {
 			rec   string
 		}
 
-		ovf string
+		ovf      string
+		ovfStale string
 	}
 
 	data *statusLogData
@@ -101,6 +154,16 @@ var termDetail = termAspects{
 	eraseScreen: fmt.Sprintf("%c[2J", 0x1b),
 }
 
+// The fields displayed on the first two status bar lines, in order. Can be changed with the
+// --status-line1 and --status-line2 options.
+var statusLine1Fields = []string{"audio", "passband", "preamp", "att", "agc", "tuner", "dw", "scope", "nr", "nb", "rfg",
+	"sql", "txdelay"}
+var statusLine2Fields = []string{"state", "freq", "mem", "band", "bandedge", "lock", "ts", "modefilt", "othervfo",
+	"split", "ritxit", "vd", "txpwr", "swr", "alc", "comp", "dtmf", "txguard", "session"}
+
+// Fields which are not displayed by default, but can be added to the status bar lines.
+var statusOptionalFields = []string{"filter", "mode", "sens"}
+
 var upArrow = "\u21d1"
 var downArrow = "\u21d3"
 
@@ -118,6 +181,50 @@ func (s *statusLogStruct) reportRTTLatency(l time.Duration) {
 	s.data.rttStr = fmt.Sprint(l.Milliseconds())
 }
 
+// generate display string for CI-V command round trip time latency
+func (s *statusLogStruct) reportCIVLatency(l time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.civRTTStr = fmt.Sprint(l.Milliseconds())
+}
+
+// path of the virtual serial port
+func (s *statusLogStruct) reportSerialDevice(path string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.serialDevice = path
+}
+
+// number of CI-V frames received from unknown addresses
+func (s *statusLogStruct) reportUnknownCIVFrames(count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.unknownCIVFrames = count
+}
+
+// number of CI-V set commands which are not confirmed by the radio yet
+func (s *statusLogStruct) reportPendingCIVSetCmds(count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.pendingCIVSets = count
+}
+
 // update string that displays current audio status
 func (s *statusLogStruct) updateAudioStateStr() {
 	if s.data.audioRecOn {
@@ -154,10 +261,12 @@ func (s *statusLogStruct) reportAudioRec(enabled bool) {
 }
 
 // update main VFO frequency value held in status log data structure
-func (s *statusLogStruct) reportFrequency(f uint) {
+func (s *statusLogStruct) reportFrequency(f uint64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("freq", fmt.Sprint(f))
+
 	if s.data == nil {
 		return
 	}
@@ -165,10 +274,12 @@ func (s *statusLogStruct) reportFrequency(f uint) {
 }
 
 // update sub-VFO frequency value held status log data structure
-func (s *statusLogStruct) reportSubFrequency(f uint) {
+func (s *statusLogStruct) reportSubFrequency(f uint64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("subfreq", fmt.Sprint(f))
+
 	if s.data == nil {
 		return
 	}
@@ -180,6 +291,8 @@ func (s *statusLogStruct) reportMode(mode string, dataMode bool, filter string)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("mode", fmt.Sprint(mode, " data:", dataMode, " ", filter))
+
 	if s.data == nil {
 		return
 	}
@@ -210,14 +323,35 @@ func (s *statusLogStruct) reportSubMode(mode string, dataMode bool, filter strin
 }
 
 // generate display string for preamp status
-func (s *statusLogStruct) reportPreamp(preamp int) {
+func (s *statusLogStruct) reportPreamp(preamp int, twoStage bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stateCSV.report("preamp", fmt.Sprint(preamp))
+
+	if s.data == nil {
+		return
+	}
+	if twoStage {
+		s.data.preamp = fmt.Sprint("PAMP", preamp)
+	} else if preamp > 0 {
+		s.data.preamp = "PAMP on"
+	} else {
+		s.data.preamp = "PAMP off"
+	}
+}
+
+// set the attenuator level in dB, it's only displayed if it's on
+func (s *statusLogStruct) reportAttenuator(dB int) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("attenuator", fmt.Sprint(dB))
+
 	if s.data == nil {
 		return
 	}
-	s.data.preamp = fmt.Sprint("PAMP", preamp)
+	s.data.attenuator = dB
 }
 
 // generate display string for AGC status
@@ -225,6 +359,8 @@ func (s *statusLogStruct) reportAGC(agc string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("agc", agc)
+
 	if s.data == nil {
 		return
 	}
@@ -242,17 +378,108 @@ func (s *statusLogStruct) reportNREnabled(enabled bool) {
 	s.data.nrEnabled = enabled
 }
 
+func (s *statusLogStruct) reportNBEnabled(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.nbEnabled = enabled
+}
+
+func (s *statusLogStruct) reportNBDepthWidth(depth, width int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.nbDepth = depth
+	s.data.nbWidth = width
+}
+
 // generate display string for (battery) voltage
 func (s *statusLogStruct) reportVd(voltage float64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("vd", fmt.Sprintf("%.1f", voltage))
+
 	if s.data == nil {
 		return
 	}
 	s.data.vd = fmt.Sprintf("%.1fV", voltage)
 }
 
+// the power source is either the battery pack, or an external power supply
+func (s *statusLogStruct) reportPowerSource(battery bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stateCSV.report("battery", fmt.Sprint(battery))
+
+	if s.data == nil {
+		return
+	}
+	if battery {
+		s.data.powerSource = "BAT"
+	} else {
+		s.data.powerSource = "EXT"
+	}
+}
+
+// set the scope state, it's not displayed if the scope is off
+func (s *statusLogStruct) reportScope(on, hold bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	switch {
+	case !on:
+		s.data.scope = ""
+	case hold:
+		s.data.scope = "SCP HOLD"
+	default:
+		s.data.scope = "SCP"
+	}
+}
+
+// set the effective passband edges in Hz, 0-0 hides the passband
+func (s *statusLogStruct) reportPassband(low, high int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	if low == 0 && high == 0 {
+		s.data.passband = ""
+	} else {
+		s.data.passband = fmt.Sprint(low, "-", high)
+	}
+}
+
+// set the IF filter width in Hz in status log data structure, 0 if unknown
+func (s *statusLogStruct) reportFilterWidth(hz int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	switch {
+	case hz == 0:
+		s.data.filterWidth = ""
+	case hz < 1000:
+		s.data.filterWidth = fmt.Sprint(hz)
+	default:
+		s.data.filterWidth = strings.TrimSuffix(fmt.Sprintf("%.1f", float64(hz)/1000), ".0") + "k"
+	}
+}
+
 // set S-level value in status log data structure
 func (s *statusLogStruct) reportS(sValue string) {
 	s.mutex.Lock()
@@ -269,21 +496,53 @@ func (s *statusLogStruct) reportOVF(ovf bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("ovf", fmt.Sprint(ovf))
+
 	if s.data == nil {
 		return
 	}
 	s.data.ovf = ovf
 }
 
-// generate display string for SWR status
+// the OVF state is displayed greyed out if it's not up to date
+func (s *statusLogStruct) reportOVFStale(stale bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.ovfStale = stale
+}
+
+// convert SWR to return loss in dB
+func swrToReturnLoss(swr float64) float64 {
+	if swr <= 1 {
+		return math.Inf(1)
+	}
+	return -20 * math.Log10((swr-1)/(swr+1))
+}
+
+// generate display string for SWR status, either as a ratio or as return loss
 func (s *statusLogStruct) reportSWR(swr float64) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("swr", fmt.Sprintf("%.1f", swr))
+
 	if s.data == nil {
 		return
 	}
-	s.data.swr = fmt.Sprintf("%.1f", swr)
+	if !swrAsReturnLoss {
+		s.data.swr = fmt.Sprintf("SWR%.1f", swr)
+		return
+	}
+	rl := swrToReturnLoss(swr)
+	if rl > 40 {
+		s.data.swr = "RL>40dB"
+	} else {
+		s.data.swr = fmt.Sprintf("RL%.1fdB", rl)
+	}
 }
 
 // generate display string for tuning step value
@@ -308,16 +567,65 @@ func (s *statusLogStruct) reportTuningStep(ts uint) {
 	}
 }
 
+func (s *statusLogStruct) reportALC(pct float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.alc = fmt.Sprintf("ALC%.0f%%", pct)
+}
+
+func (s *statusLogStruct) reportComp(db float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.comp = fmt.Sprintf("COMP%.0fdB", db)
+}
+
+// set antenna tuner in-line status in status log data structure
+func (s *statusLogStruct) reportTunerEnabled(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.tunerEnabled = enabled
+}
+
 // set push-to-talk (aka Tx) status in status log data structure
 func (s *statusLogStruct) reportPTT(ptt, tune bool) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("ptt", fmt.Sprint(ptt))
+	stateCSV.report("tune", fmt.Sprint(tune))
+	sessionStats.reportTX(ptt || tune)
+
 	if s.data == nil {
 		return
 	}
 	s.data.tune = tune
 	s.data.ptt = ptt
+	if !tune {
+		s.data.tuneDeadline = time.Time{}
+	}
+}
+
+// set the time when the tune timeout fires, a countdown is displayed until then while tuning
+func (s *statusLogStruct) reportTuneDeadline(t time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.tuneDeadline = t
 }
 
 // convert int value 0 - 255 to a floating point percentage
@@ -331,6 +639,8 @@ func (s *statusLogStruct) reportTxPower(level int) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("txpwr", fmt.Sprintf("%.1f", asPercentage(level)))
+
 	if s.data == nil {
 		return
 	}
@@ -359,6 +669,17 @@ func (s *statusLogStruct) reportSQL(level int) {
 	s.data.sql = fmt.Sprintf("%3.1f%%", asPercentage(level))
 }
 
+// generate the display string for the combined RF gain and squelch sensitivity
+func (s *statusLogStruct) reportSensitivity(level int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.sensitivity = fmt.Sprintf("%3.1f%%", asPercentage(level))
+}
+
 // generate the display string for noise reduction level
 func (s *statusLogStruct) reportNR(level int) {
 	s.mutex.Lock()
@@ -370,11 +691,23 @@ func (s *statusLogStruct) reportNR(level int) {
 	s.data.nr = fmt.Sprintf("%3.1f%%", asPercentage(level))
 }
 
+func (s *statusLogStruct) reportNB(level int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.nb = fmt.Sprintf("%3.1f%%", asPercentage(level))
+}
+
 // generate the display string for split frequency operating mode
 func (s *statusLogStruct) reportSplit(mode splitMode, split string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	stateCSV.report("split", strings.TrimSpace(split))
+
 	if s.data == nil {
 		return
 	}
@@ -386,6 +719,202 @@ func (s *statusLogStruct) reportSplit(mode splitMode, split string) {
 	}
 }
 
+// set the repeater offset used in DUP- and DUP+ modes
+func (s *statusLogStruct) reportDuplexOffset(offset uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.duplexOffset = offset
+}
+
+// set the active VFO in status log data structure
+func (s *statusLogStruct) reportVFO(vfoBActive bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.vfoBActive = vfoBActive
+}
+
+// set the current band name in status log data structure, empty if the frequency is outside of the known bands
+func (s *statusLogStruct) reportBand(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stateCSV.report("band", name)
+	sessionStats.reportBand(name)
+	sessionLog.reportBand(name)
+
+	if s.data == nil {
+		return
+	}
+	s.data.band = name
+}
+
+// set band edge warning in status log data structure, empty if there's no warning
+func (s *statusLogStruct) reportBandEdge(warning string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stateCSV.report("bandedge", warning)
+
+	if s.data == nil {
+		return
+	}
+	s.data.bandEdge = warning
+}
+
+// set the name of the watched frequency the main VFO is on, empty if it's not on one
+func (s *statusLogStruct) reportWatchedFreq(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.watchedFreq = name
+}
+
+// set dial lock status in status log data structure
+func (s *statusLogStruct) reportDialLock(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.dialLock = enabled
+}
+
+// set VFO/memory mode in status log data structure
+func (s *statusLogStruct) reportMemoryMode(memoryMode bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.memoryMode = memoryMode
+}
+
+// set the selected memory channel and its name (empty if it has none)
+func (s *statusLogStruct) reportMemoryChannel(ch int, name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.memoryCh = ch
+	s.data.memoryName = name
+}
+
+// set dual watch status in status log data structure
+func (s *statusLogStruct) reportDualWatch(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.dualWatch = enabled
+}
+
+// set RIT enabled status in status log data structure
+func (s *statusLogStruct) reportRITEnabled(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.ritEnabled = enabled
+}
+
+// set XIT (delta TX) enabled status in status log data structure
+func (s *statusLogStruct) reportXITEnabled(enabled bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.xitEnabled = enabled
+}
+
+// generate the display string for the XIT offset
+func (s *statusLogStruct) reportXITOffset(offset int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.xitOffset = fmt.Sprintf("%+d", offset)
+}
+
+// set the recently received DTMF digits in status log data structure
+func (s *statusLogStruct) reportDTMF(digits string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.dtmf = digits
+	s.data.dtmfAt = time.Now()
+}
+
+func (s *statusLogStruct) reportTXDelay(delay string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.txDelay = delay
+}
+
+func (s *statusLogStruct) reportTXInhibit(inhibit bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.txInhibit = inhibit
+}
+
+// set the reason of the last denied transmit, it's displayed for a few seconds
+func (s *statusLogStruct) reportTXDenied(reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	log.Error("tx denied: ", reason)
+
+	if s.data == nil {
+		return
+	}
+	s.data.txDenied = reason
+	s.data.txDeniedAt = time.Now()
+}
+
+// set the text input of a hotkey (with the prompt) to display instead of the first status line
+func (s *statusLogStruct) reportInput(input string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data == nil {
+		return
+	}
+	s.data.input = input
+}
+
 // clears the entire line the cursor is located on
 func (s *statusLogStruct) clearStatusLine() {
 	fmt.Print(termDetail.eraseLine)
@@ -395,7 +924,8 @@ func (s *statusLogStruct) clearStatusLine() {
 //
 //	 (NOTE: s.isRealtimeInternal merely returns true/false for if in terminal, this should be cleaned up for clarity)
 //		if running in a terminal, print the current status to the console and reposition the cursor to the first line of output)
-//	  if not, send just the last line (packet rate info) to the debugging log
+//	  if not, send all status lines joined as a single line to the log, so radio state is also captured
+//	  when the output is redirected to a file or journal
 func (s *statusLogStruct) print() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -408,8 +938,20 @@ func (s *statusLogStruct) print() {
 		s.clearStatusLine()
 		fmt.Printf(s.data.line3+"%v%v", termDetail.cursorUp, termDetail.cursorUp)
 	} else {
-		log.PrintStatusLog(s.data.line3)
+		log.PrintStatusLog(s.getSummaryLine())
+	}
+}
+
+// join all status lines into a single line for plain (non-terminal) output
+func (s *statusLogStruct) getSummaryLine() string {
+	var lines []string
+	for _, l := range []string{s.data.line1, s.data.line2, s.data.line3} {
+		l = strings.TrimSpace(l)
+		if l != "" {
+			lines = append(lines, l)
+		}
 	}
+	return strings.Join(lines, " | ")
 }
 
 // use whitespace padding on left to right-justify the string
@@ -434,39 +976,109 @@ func (s *statusLogStruct) padRight(str string, length int) string {
 	return str
 }
 
+func (s *statusLogStruct) joinFields(fields map[string]string, names []string) (res string) {
+	for _, name := range names {
+		res += fields[name]
+	}
+	return
+}
+
 // update variables used for status output using current values to regenerate the strings to display
 func (s *statusLogStruct) update() {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	var (
-		filterStr  string
-		preampStr  string
-		agcStr     string
-		nrStr      string
-		rfGainStr  string
-		sqlStr     string
-		stateStr   string
-		tsStr      string
-		modeStr    string
-		vdStr      string
-		txPowerStr string
-		splitStr   string
-		swrStr     string
+		filterStr   string
+		passbandStr string
+		scopeStr    string
+		preampStr   string
+		attStr      string
+		agcStr      string
+		tunerStr    string
+		dwStr       string
+		lockStr     string
+		memStr      string
+		bandEdgeStr string
+		bandStr     string
+		nrStr       string
+		nbStr       string
+		rfGainStr   string
+		sqlStr      string
+		sensStr     string
+		stateStr    string
+		tsStr       string
+		modeStr     string
+		modeFiltStr string
+		vdStr       string
+		txPowerStr  string
+		splitStr    string
+		freqStr     string
+		subVFOStr   string
+		ritXITStr   string
+		swrStr      string
+		alcStr      string
+		compStr     string
+		dtmfStr     string
+		txGuardStr  string
+		txDelayStr  string
+		sessionStr  string
 	)
 
 	if s.data.filter != "" {
 		filterStr = " " + s.data.filter
 	}
 
+	if s.data.passband != "" {
+		passbandStr = " PB " + s.data.passband
+	}
+
 	if s.data.preamp != "" {
 		preampStr = " " + s.data.preamp
 	}
 
+	if s.data.attenuator > 0 {
+		attStr = fmt.Sprint(" ATT", s.data.attenuator)
+	}
+
 	if s.data.agc != "" {
 		agcStr = " " + s.data.agc
 	}
 
+	if s.data.tunerEnabled {
+		tunerStr = " ATU"
+	}
+
+	if s.data.dualWatch {
+		dwStr = " DW"
+	}
+
+	if s.data.scope != "" {
+		scopeStr = " " + s.data.scope
+	}
+
+	if showBand && s.data.band != "" {
+		bandStr = " " + s.data.band
+	}
+
+	if s.data.bandEdge != "" {
+		bandEdgeStr = " " + s.preGenerated.bandEdgeColor.Sprint(s.data.bandEdge)
+	}
+
+	if s.data.dialLock {
+		lockStr = " " + s.preGenerated.lockColor.Sprint("LOCK")
+	}
+
+	if s.data.memoryMode {
+		memStr = " MEM"
+		if s.data.memoryCh >= 0 {
+			memStr += fmt.Sprint(" ", s.data.memoryCh)
+			if s.data.memoryName != "" {
+				memStr += ": " + s.data.memoryName
+			}
+		}
+	}
+
 	if s.data.nr != "" {
 		nrStr = " NR"
 		if s.data.nrEnabled {
@@ -476,6 +1088,18 @@ func (s *statusLogStruct) update() {
 		}
 	}
 
+	if s.data.nb != "" {
+		nbStr = " NB"
+		if s.data.nbEnabled {
+			nbStr += " " + s.data.nb
+			if s.data.nbDepth > 0 {
+				nbStr += fmt.Sprint(" ", s.data.nbDepth, "/", s.data.nbWidth)
+			}
+		} else {
+			nbStr += "-"
+		}
+	}
+
 	if s.data.rfGain != "" {
 		rfGainStr = " rfg " + s.data.rfGain
 	}
@@ -483,15 +1107,30 @@ func (s *statusLogStruct) update() {
 	if s.data.sql != "" {
 		sqlStr = " sql " + s.data.sql
 	}
-	s.data.line1 = fmt.Sprint(s.data.audioStateStr, filterStr, preampStr, agcStr, nrStr, rfGainStr, sqlStr)
+
+	if s.data.sensitivity != "" {
+		sensStr = " sens " + s.data.sensitivity
+	}
 
 	if s.data.tune {
 		stateStr = s.preGenerated.stateStr.tune
+		if !s.data.tuneDeadline.IsZero() {
+			remaining := time.Until(s.data.tuneDeadline)
+			if remaining < 0 {
+				remaining = 0
+			}
+			stateStr += fmt.Sprintf("%ds ", int(remaining.Round(time.Second).Seconds()))
+		}
 	} else if s.data.ptt {
 		stateStr = s.preGenerated.stateStr.tx
+		if splitTXCheck && s.data.splitMode == splitModeOn {
+			stateStr += s.preGenerated.splitTXColor.Sprintf(" TX %.6f ", float64(s.data.subFrequency)/1000000)
+		}
 	} else {
 		var ovfStr string
-		if s.data.ovf {
+		if s.data.ovfStale {
+			ovfStr = s.preGenerated.ovfStale
+		} else if s.data.ovf {
 			ovfStr = s.preGenerated.ovf
 		}
 		if len(s.data.s) <= 2 {
@@ -507,30 +1146,155 @@ func (s *statusLogStruct) update() {
 	}
 
 	if s.data.mode != "" {
-		modeStr = " " + s.data.mode + s.data.dataMode
+		modeStr = " " + s.data.mode
+		// A small -D suffix is easy to miss, so data mode gets a badge to make it clear which audio path
+		// (data or mic) is used on TX.
+		if s.data.dataMode != "" {
+			modeStr += " " + s.preGenerated.dataModeColor.Sprint(" DATA ")
+		}
+		// The filter slot name alone means nothing if the filter widths were customized in the radio, so
+		// the combined element also shows the actual width when it's known.
+		modeFiltStr = modeStr + filterStr
+		if s.data.filterWidth != "" {
+			modeFiltStr += " " + s.data.filterWidth
+		}
 	}
 
+	if s.data.powerSource != "" {
+		vdStr = " " + s.data.powerSource
+	}
 	if s.data.vd != "" {
-		vdStr = " " + s.data.vd
+		vdStr += " " + s.data.vd
 	}
 
 	if s.data.txPower != "" {
 		txPowerStr = " txpwr " + s.data.txPower
 	}
 
+	freqStr = fmt.Sprintf("%.6f", float64(s.data.frequency)/1000000)
+	if s.data.watchedFreq != "" {
+		freqStr = s.preGenerated.watchedFreqColor.Sprint(freqStr + " " + s.data.watchedFreq)
+	}
+	if showBothVFOs {
+		mainVFO, subVFO := "A", "B"
+		if s.data.vfoBActive {
+			mainVFO, subVFO = "B", "A"
+		}
+		freqStr = mainVFO + ":" + freqStr
+		subVFOStr = fmt.Sprintf(" %s:%.6f/%s%s/%s", subVFO, float64(s.data.subFrequency)/1000000,
+			s.data.subMode, s.data.subDataMode, s.data.subFilter)
+	}
+
 	if s.data.split != "" {
 		splitStr = " " + s.data.split
-		if s.data.splitMode == splitModeOn {
+		if s.data.splitMode == splitModeOn && !showBothVFOs {
 			splitStr += fmt.Sprintf("/%.6f/%s%s/%s", float64(s.data.subFrequency)/1000000,
 				s.data.subMode, s.data.subDataMode, s.data.subFilter)
 		}
+		// In duplex modes the radio transmits on the RX frequency shifted by the repeater offset.
+		if s.data.duplexOffset > 0 {
+			switch s.data.splitMode {
+			case splitModeDUPMinus:
+				if s.data.frequency > s.data.duplexOffset {
+					splitStr += fmt.Sprintf("/TX %.6f", float64(s.data.frequency-s.data.duplexOffset)/1000000)
+				}
+			case splitModeDUPPlus:
+				splitStr += fmt.Sprintf("/TX %.6f", float64(s.data.frequency+s.data.duplexOffset)/1000000)
+			}
+		}
+	}
+
+	if s.data.ritEnabled {
+		ritXITStr += " " + s.preGenerated.ritXITColor.Sprint("RIT"+s.data.xitOffset)
+	}
+	if s.data.xitEnabled {
+		ritXITStr += " " + s.preGenerated.ritXITColor.Sprint("XIT"+s.data.xitOffset)
 	}
 
 	if (s.data.tune || s.data.ptt) && s.data.swr != "" {
-		swrStr = " SWR" + s.data.swr
+		swrStr = " " + s.data.swr
+	}
+	if s.data.ptt {
+		if s.data.alc != "" {
+			alcStr = " " + s.data.alc
+		}
+		if s.data.comp != "" {
+			compStr = " " + s.data.comp
+		}
+	}
+	if s.data.dtmf != "" && time.Since(s.data.dtmfAt) < dtmfDisplayDuration {
+		dtmfStr = " DTMF " + s.data.dtmf
+	}
+	if s.data.txDelay != "" && s.data.txDelay != civTXDelays[0] {
+		txDelayStr = " TXD " + s.data.txDelay
+	}
+	if s.data.txInhibit {
+		txGuardStr = " " + s.preGenerated.bandEdgeColor.Sprint("TX INHIBIT")
+	}
+	if s.data.txDenied != "" && time.Since(s.data.txDeniedAt) < txDeniedDisplayDuration {
+		txGuardStr += " " + s.preGenerated.bandEdgeColor.Sprint("TX DENIED: "+s.data.txDenied)
 	}
-	s.data.line2 = fmt.Sprint(stateStr, " ", fmt.Sprintf("%.6f", float64(s.data.frequency)/1000000),
-		tsStr, modeStr, splitStr, vdStr, txPowerStr, swrStr)
+
+	if maxSessionDuration > 0 {
+		remaining := maxSessionDuration - time.Since(s.data.startTime)
+		if remaining <= 0 && !s.data.sessionEnd {
+			s.data.sessionEnd = true
+			// Logging needs the status log's mutex, so it's done from another goroutine.
+			go func() {
+				log.Print("max session duration of ", maxSessionDuration, " reached, disconnecting")
+				quitChan <- true
+			}()
+		}
+		if remaining < 0 {
+			remaining = 0
+		}
+		if remaining <= maxSessionWarning {
+			sessionStr = " " + s.preGenerated.bandEdgeColor.Sprint("SESSION ENDS IN ", remaining.Round(time.Second))
+		}
+	}
+
+	fields := map[string]string{
+		"audio":    s.data.audioStateStr,
+		"filter":   filterStr,
+		"passband": passbandStr,
+		"preamp":   preampStr,
+		"att":      attStr,
+		"agc":      agcStr,
+		"tuner":    tunerStr,
+		"dw":       dwStr,
+		"scope":    scopeStr,
+		"nr":       nrStr,
+		"nb":       nbStr,
+		"rfg":      rfGainStr,
+		"sql":      sqlStr,
+		"sens":     sensStr,
+		"state":    stateStr,
+		"freq":     " " + freqStr,
+		"mem":      memStr,
+		"band":     bandStr,
+		"bandedge": bandEdgeStr,
+		"lock":     lockStr,
+		"ts":       tsStr,
+		"mode":     modeStr,
+		"modefilt": modeFiltStr,
+		"othervfo": subVFOStr,
+		"split":    splitStr,
+		"ritxit":   ritXITStr,
+		"vd":       vdStr,
+		"txpwr":    txPowerStr,
+		"swr":      swrStr,
+		"alc":      alcStr,
+		"comp":     compStr,
+		"dtmf":     dtmfStr,
+		"txguard":  txGuardStr,
+		"session":  sessionStr,
+		"txdelay":  txDelayStr,
+	}
+	s.data.line1 = s.joinFields(fields, statusLine1Fields)
+	if s.data.input != "" {
+		s.data.line1 = s.data.input
+	}
+	s.data.line2 = s.joinFields(fields, statusLine2Fields)
 
 	up, down, lost, retransmits := netstat.get()
 	lostStr := "0"
@@ -542,12 +1306,39 @@ func (s *statusLogStruct) update() {
 		retransmitsStr = s.preGenerated.retransmitsColor.Sprint(" ", retransmits, " ")
 	}
 
+	bufDepth, underruns, overruns := netstat.getAudioBufStats()
+	underrunsStr := "0"
+	if underruns > 0 {
+		underrunsStr = s.preGenerated.retransmitsColor.Sprint(" ", underruns, " ")
+	}
+	overrunsStr := "0"
+	if overruns > 0 {
+		overrunsStr = s.preGenerated.retransmitsColor.Sprint(" ", overruns, " ")
+	}
+
+	var serialDeviceStr string
+	if s.data.serialDevice != "" {
+		serialDeviceStr = "  - pty: " + s.data.serialDevice
+	}
+
+	var unknownCIVStr string
+	if s.data.unknownCIVFrames > 0 {
+		unknownCIVStr = " " + s.preGenerated.lostColor.Sprint(" ", s.data.unknownCIVFrames, " unk ")
+	}
+	var pendingCIVSetsStr string
+	if s.data.pendingCIVSets > 0 {
+		pendingCIVSetsStr = " " + s.preGenerated.retransmitsColor.Sprint(" ", s.data.pendingCIVSets, " set ")
+	}
+
 	s.data.line3 = fmt.Sprint(
 		" [", s.padLeft(netstat.formatByteCount(up), 8), "/s "+upArrow+"] ",
 		" [", s.padLeft(netstat.formatByteCount(down), 8), "/s "+downArrow+"] ",
 		" [", s.padLeft(s.data.rttStr, 3), "ms "+roundTripArrow+"] ",
+		" [", s.padLeft(s.data.civRTTStr, 3), "ms CI-V", pendingCIVSetsStr, unknownCIVStr, "] ",
 		" re-Tx ", retransmitsStr, "/1m lost ", lostStr, "/1m",
+		" jbuf ", s.padLeft(fmt.Sprint(bufDepth.Milliseconds()), 3), "ms u ", underrunsStr, "/1m o ", overrunsStr, "/1m",
 		"  - uptime: ", s.padLeft(fmt.Sprint(time.Since(s.data.startTime).Round(time.Second)), 6),
+		serialDeviceStr,
 		"\r")
 
 	if s.isRealtimeInternal() {
@@ -606,7 +1397,9 @@ func (s *statusLogStruct) startPeriodicPrint() {
 		s:             "S0",
 		startTime:     time.Now(),
 		rttStr:        "?",
+		civRTTStr:     "?",
 		audioStateStr: s.preGenerated.audioStateStr.off,
+		memoryCh:      -1,
 	}
 
 	s.stopChan = make(chan bool)
@@ -635,6 +1428,29 @@ func (s *statusLogStruct) stopPeriodicPrint() {
 	}
 }
 
+// read the current terminal size into termDetail
+func (s *statusLogStruct) updateTermSize() {
+	cols, rows, err := terminal.GetSize(int(os.Stdout.Fd()))
+	if err == nil {
+		termDetail.cols = cols
+		termDetail.rows = rows
+	} else {
+		// if redirecting to a file these are zeros, and that's a problem
+		// yes, needs actual error check too
+		termDetail.cols = 120
+		termDetail.rows = 20
+	}
+}
+
+// erase the screen and move the cursor down so the status bar is displayed at the bottom of the terminal
+func (s *statusLogStruct) clearScreen() {
+	var vertWhitespace string
+	if termDetail.rows > 10 {
+		vertWhitespace = strings.Repeat(termDetail.cursorDown, termDetail.rows-10)
+	}
+	fmt.Printf("%v%v", termDetail.eraseScreen, vertWhitespace)
+}
+
 // initialization tasks
 //
 //	initialize keyboard/set log timer depending on if running in terminal or not
@@ -644,27 +1460,30 @@ func (s *statusLogStruct) initIfNeeded() {
 		return
 	}
 
-	if quietLog || (!isatty.IsTerminal(os.Stdout.Fd()) && statusLogInterval < time.Second) {
+	if headless {
+		// Full functionality without a terminal: the status is logged with the set interval as a plain
+		// line, and the keyboard and the screen are not touched.
+		color.NoColor = true
+		if quietLog {
+			statusLogInterval = time.Second
+		}
+	} else if quietLog || (!isatty.IsTerminal(os.Stdout.Fd()) && statusLogInterval < time.Second) {
 		statusLogInterval = time.Second
 	} else {
 		keyboard.init()
 	}
 
-	cols, rows, err := terminal.GetSize(int(os.Stdout.Fd()))
-	if err == nil {
-		termDetail.cols = cols
-		termDetail.rows = rows
-	} else {
-		// if redirecting to a file these are zeros, and that's a problem
-		// yes, needs actual error check too
-		termDetail.cols = 120
-		termDetail.rows = 20
-	}
+	s.updateTermSize()
 
 	// consider doing this with a nice looking start up screen too
 	//  what'd be kinda useful would be a nice map of the hotkeys
-	vertWhitespace := strings.Repeat(termDetail.cursorDown, termDetail.rows-10)
-	fmt.Printf("%v%v", termDetail.eraseScreen, vertWhitespace)
+	if !headless {
+		s.clearScreen()
+	}
+
+	if s.isRealtimeInternal() {
+		s.watchTermResize()
+	}
 
 	c := color.New(color.FgHiWhite)
 	c.Add(color.BgWhite)
@@ -683,6 +1502,7 @@ func (s *statusLogStruct) initIfNeeded() {
 	c = color.New(color.FgHiWhite)
 	c.Add(color.BgRed)
 	s.preGenerated.ovf = c.Sprint(" OVF ")
+	s.preGenerated.ovfStale = color.New(color.FgHiBlack).Sprint(" OVF?")
 
 	s.preGenerated.retransmitsColor = color.New(color.FgHiWhite)
 	s.preGenerated.retransmitsColor.Add(color.BgYellow)
@@ -690,4 +1510,14 @@ func (s *statusLogStruct) initIfNeeded() {
 	s.preGenerated.lostColor.Add(color.BgRed)
 
 	s.preGenerated.splitColor = color.New(color.FgHiMagenta)
+	s.preGenerated.ritXITColor = color.New(color.FgHiCyan)
+	s.preGenerated.lockColor = color.New(color.FgHiRed)
+	s.preGenerated.bandEdgeColor = color.New(color.FgHiWhite, color.BlinkRapid)
+	s.preGenerated.bandEdgeColor.Add(color.BgRed)
+	s.preGenerated.dataModeColor = color.New(color.FgBlack)
+	s.preGenerated.dataModeColor.Add(color.BgHiYellow)
+	s.preGenerated.watchedFreqColor = color.New(color.FgHiWhite)
+	s.preGenerated.watchedFreqColor.Add(color.BgBlue)
+	s.preGenerated.splitTXColor = color.New(color.FgHiWhite)
+	s.preGenerated.splitTXColor.Add(color.BgMagenta)
 }
@@ -179,6 +179,7 @@ func (s *streamCommon) requestRetransmit(r seqNumRange) error {
 	if diff > maxRetransmitRequestPacketCount {
 		return errors.New("retransmit range too large")
 	}
+	netstat.reportRetransmitGap(diff + 1)
 
 	if diff == 0 {
 		log.Debug(s.name+"/requesting pkt #", r[0], " retransmit")
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// The terminal size is refreshed and the status bar is redrawn when the terminal gets resized,
+// otherwise the cursor movements in the status bar printing would corrupt the display.
+func (s *statusLogStruct) watchTermResize() {
+	sigwinch := make(chan os.Signal, 1)
+	signal.Notify(sigwinch, syscall.SIGWINCH)
+
+	go func() {
+		for range sigwinch {
+			s.mutex.Lock()
+			s.updateTermSize()
+			s.clearScreen()
+			s.mutex.Unlock()
+
+			if s.isRealtime() {
+				s.print()
+			}
+		}
+	}()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const ttsCheckInterval = time.Second
+const ttsMinSChange = 2 // S units
+
+// The text-to-speech readout periodically checks the frequency, mode and S level and if they change
+// significantly, then the new values are piped to the stdin of the configured TTS command.
+type ttsStruct struct {
+	deinitNeededChan   chan bool
+	deinitFinishedChan chan bool
+
+	spokenFreq uint64
+	spokenMode string
+	spokenS    float64
+
+	// The frequency is only spoken if it's the same on two consecutive checks, so we don't speak
+	// while tuning.
+	prevFreq uint64
+}
+
+var tts ttsStruct
+
+func (t *ttsStruct) getStatus() (freq uint64, mode string, sValue string) {
+	statusLog.mutex.Lock()
+	defer statusLog.mutex.Unlock()
+
+	if statusLog.data == nil {
+		return
+	}
+	mode = statusLog.data.mode
+	if statusLog.data.dataMode != "" {
+		mode += " data"
+	}
+	return statusLog.data.frequency, mode, statusLog.data.s
+}
+
+// Runs the TTS command with the given text on its stdin and waits for it to finish, so announcements
+// won't overlap.
+func (t *ttsStruct) speak(text string) {
+	s := strings.Split(ttsCmd, " ")
+	cmd := exec.Command(s[0], s[1:]...)
+	cmd.Stdin = strings.NewReader(text + "\n")
+	if err := cmd.Run(); err != nil {
+		log.Error("tts error: ", err)
+	}
+}
+
+func (t *ttsStruct) check() {
+	freq, mode, sValue := t.getStatus()
+	var texts []string
+
+	if freq != 0 && freq == t.prevFreq && freq != t.spokenFreq {
+		texts = append(texts, strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.4f", float64(freq)/1000000), "0"), ".")+
+			" megahertz")
+		t.spokenFreq = freq
+	}
+	t.prevFreq = freq
+
+	if mode != "" && mode != t.spokenMode {
+		texts = append(texts, mode)
+		t.spokenMode = mode
+	}
+
+	s, _ := sMeter.parseS(sValue)
+	if sValue != "" && (s-t.spokenS >= ttsMinSChange || t.spokenS-s >= ttsMinSChange) {
+		texts = append(texts, "S "+strings.TrimPrefix(sValue, "S"))
+		t.spokenS = s
+	}
+
+	if len(texts) > 0 {
+		t.speak(strings.Join(texts, ", "))
+	}
+}
+
+func (t *ttsStruct) loop() {
+	for {
+		select {
+		case <-time.After(ttsCheckInterval):
+			t.check()
+		case <-t.deinitNeededChan:
+			t.deinitFinishedChan <- true
+			return
+		}
+	}
+}
+
+func (t *ttsStruct) initIfNeeded() {
+	if t.deinitNeededChan != nil || ttsCmd == "" {
+		return
+	}
+
+	log.Print("starting text-to-speech readout using ", ttsCmd)
+
+	t.deinitNeededChan = make(chan bool)
+	t.deinitFinishedChan = make(chan bool)
+	go t.loop()
+}
+
+func (t *ttsStruct) deinit() {
+	if t.deinitNeededChan != nil {
+		t.deinitNeededChan <- true
+		<-t.deinitFinishedChan
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Optional processing of the audio recorded from the default sound device before it's sent to the
+// transceiver: a high-pass filter to remove the boominess of laptop mics, pre-emphasis and a simple
+// compressor. Audio from the virtual sound card is not processed, as it's usually from digital mode apps.
+
+const txAudioPreEmphasisCorner = 800         // Hz
+const txAudioPreEmphasisUnityGainFreq = 3000 // Hz
+const txAudioCompressorThreshold = 0.1       // -20dBFS
+const txAudioCompressorAttack = 0.005        // seconds
+const txAudioCompressorRelease = 0.1         // seconds
+
+type txAudioProcStruct struct {
+	initialized bool
+
+	// High-pass biquad filter coefficients and state.
+	hpB0, hpB1, hpB2, hpA1, hpA2 float64
+	hpX1, hpX2, hpY1, hpY2       float64
+
+	preEmphasisCoeff float64
+	preEmphasisGain  float64
+	preEmphasisX1    float64
+
+	attackCoeff  float64
+	releaseCoeff float64
+	envelope     float64
+	makeupGain   float64
+}
+
+var txAudioProc txAudioProcStruct
+
+func (p *txAudioProcStruct) isEnabled() bool {
+	return txAudioHighPassFreq > 0 || txAudioPreEmphasis || txAudioCompressRatio > 1
+}
+
+func (p *txAudioProcStruct) init() {
+	fs := float64(audioSampleRate)
+
+	if txAudioHighPassFreq > 0 {
+		// Butterworth high-pass from the Audio EQ Cookbook.
+		w0 := 2 * math.Pi * float64(txAudioHighPassFreq) / fs
+		alpha := math.Sin(w0) / math.Sqrt2 // Q = 1/sqrt(2)
+		cosW0 := math.Cos(w0)
+		a0 := 1 + alpha
+		p.hpB0 = (1 + cosW0) / 2 / a0
+		p.hpB1 = -(1 + cosW0) / a0
+		p.hpB2 = (1 + cosW0) / 2 / a0
+		p.hpA1 = -2 * cosW0 / a0
+		p.hpA2 = (1 - alpha) / a0
+	}
+
+	// First order pre-emphasis, normalized to unity gain at txAudioPreEmphasisUnityGainFreq.
+	p.preEmphasisCoeff = math.Exp(-2 * math.Pi * txAudioPreEmphasisCorner / fs)
+	w := 2 * math.Pi * txAudioPreEmphasisUnityGainFreq / fs
+	p.preEmphasisGain = 1 / math.Sqrt(1-2*p.preEmphasisCoeff*math.Cos(w)+p.preEmphasisCoeff*p.preEmphasisCoeff)
+
+	if txAudioCompressRatio > 1 {
+		p.attackCoeff = math.Exp(-1 / (txAudioCompressorAttack * fs))
+		p.releaseCoeff = math.Exp(-1 / (txAudioCompressorRelease * fs))
+		// The makeup gain brings full scale back to full scale, so only the quieter parts get louder.
+		p.makeupGain = 1 / (txAudioCompressorThreshold * math.Pow(1/txAudioCompressorThreshold, 1/float64(txAudioCompressRatio)))
+	}
+
+	p.initialized = true
+}
+
+func (p *txAudioProcStruct) highPass(x float64) float64 {
+	y := p.hpB0*x + p.hpB1*p.hpX1 + p.hpB2*p.hpX2 - p.hpA1*p.hpY1 - p.hpA2*p.hpY2
+	p.hpX2, p.hpX1 = p.hpX1, x
+	p.hpY2, p.hpY1 = p.hpY1, y
+	return y
+}
+
+func (p *txAudioProcStruct) preEmphasis(x float64) float64 {
+	y := (x - p.preEmphasisCoeff*p.preEmphasisX1) * p.preEmphasisGain
+	p.preEmphasisX1 = x
+	return y
+}
+
+// Expects samples in the -1..1 range.
+func (p *txAudioProcStruct) compress(x float64) float64 {
+	level := math.Abs(x)
+	if level > p.envelope {
+		p.envelope = p.attackCoeff*p.envelope + (1-p.attackCoeff)*level
+	} else {
+		p.envelope = p.releaseCoeff*p.envelope + (1-p.releaseCoeff)*level
+	}
+
+	gain := p.makeupGain
+	if p.envelope > txAudioCompressorThreshold {
+		compressed := txAudioCompressorThreshold * math.Pow(p.envelope/txAudioCompressorThreshold, 1/float64(txAudioCompressRatio))
+		gain *= compressed / p.envelope
+	}
+	return x * gain
+}
+
+// Processes the given s16le PCM data in place.
+func (p *txAudioProcStruct) process(pcm []byte) {
+	if !p.initialized {
+		p.init()
+	}
+
+	for i := 0; i+1 < len(pcm); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(pcm[i:i+2]))) / 32768
+		if txAudioHighPassFreq > 0 {
+			x = p.highPass(x)
+		}
+		if txAudioPreEmphasis {
+			x = p.preEmphasis(x)
+		}
+		if txAudioCompressRatio > 1 {
+			x = p.compress(x)
+		}
+		x = math.Max(-32768, math.Min(x*32768, 32767))
+		binary.LittleEndian.PutUint16(pcm[i:i+2], uint16(int16(x)))
+	}
+}
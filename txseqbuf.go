@@ -5,7 +5,10 @@ import "time"
 // This value is sent to the transceiver and - according to my observations - it will use
 // this as it's RX buf length. Note that if it is set to larger than 500-600ms then audio TX
 // won't work (small radio memory?) - HA2NON
-const txSeqBufLength = 300 * time.Millisecond
+// It can be set with a command line argument, which is limited to maxTxSeqBufLength.
+var txSeqBufLength = 300 * time.Millisecond
+
+const maxTxSeqBufLength = 500 * time.Millisecond
 
 type txSeqBufEntry struct {
 	seq     seqNum